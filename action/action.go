@@ -0,0 +1,183 @@
+// Package action executes commands of trigger sections with Android init action queue
+// semantics.
+//
+// Events and property changes are queued and processed in order.  Processing an event
+// selects all triggers it matches, in the order the triggers were added, and their commands
+// are executed one at a time before the next event is dequeued.  Commands may queue further
+// events, e.g. with the trigger command, or change properties, which queues re-evaluation of
+// property triggers.
+//
+// A trigger with an event condition matches when the event is processed and all of its
+// property conditions hold.  A trigger with only property conditions matches when one of the
+// properties changes to a matching value and the other conditions hold.
+package action
+
+import (
+	"github.com/tie/x/config"
+)
+
+// Executor executes trigger commands.
+type Executor interface {
+	// Execute executes the command with property references expanded.
+	Execute(cmd config.Command) error
+}
+
+// ExecutorFunc is Executor backed by a function.
+type ExecutorFunc func(cmd config.Command) error
+
+func (f ExecutorFunc) Execute(cmd config.Command) error {
+	return f(cmd)
+}
+
+// event is an element of the action queue.
+type event struct {
+	// name is the event name, or the changed property name of property events
+	name string
+	// property is set for property changes, with empty name matching all property triggers
+	// whose conditions hold
+	property bool
+	value string
+}
+
+// Manager queues events and executes commands of triggers they match.  It is not safe for
+// concurrent use, and commands are executed from Step.
+type Manager struct {
+	// Executor executes commands.
+	Executor Executor
+	// Properties are values of properties for property conditions and references in command
+	// arguments.  May be nil.
+	Properties config.Properties
+	// OnError is called for commands that failed to expand or execute.  May be nil.
+	OnError func(cmd config.Command, err error)
+
+	triggers []*config.Trigger
+	queue []event
+	// current are triggers matched by the last dequeued event, and command is the index of
+	// the next command of the first one
+	current []*config.Trigger
+	command int
+}
+
+// Add adds triggers of the files, in order.
+func (m *Manager) Add(files ...*config.File) {
+	for _, f := range files {
+		m.triggers = append(m.triggers, f.Triggers...)
+	}
+}
+
+// QueueEvent queues the event, e.g. early-init, init or boot.
+func (m *Manager) QueueEvent(name string) {
+	m.queue = append(m.queue, event{name: name})
+}
+
+// QueuePropertyChange queues re-evaluation of triggers with conditions on the property.
+// Properties must already have the new value when other conditions are checked, so it is
+// usually called after setting the property.
+func (m *Manager) QueuePropertyChange(name, value string) {
+	m.queue = append(m.queue, event{name: name, property: true, value: value})
+}
+
+// QueueAllPropertyTriggers queues triggers with only property conditions that hold for the
+// current property values, as done once properties are loaded on boot.
+func (m *Manager) QueueAllPropertyTriggers() {
+	m.queue = append(m.queue, event{property: true})
+}
+
+// Pending reports whether there are queued events or commands left to execute.
+func (m *Manager) Pending() bool {
+	return len(m.queue) > 0 || len(m.current) > 0
+}
+
+// Step executes a single command.  It reports whether there was a command to execute.
+func (m *Manager) Step() bool {
+	for {
+		// skip finished triggers and triggers without commands
+		for len(m.current) > 0 && m.command >= len(m.current[0].Commands) {
+			m.current = m.current[1:]
+			m.command = 0
+		}
+		if len(m.current) > 0 {
+			break
+		}
+		if len(m.queue) == 0 {
+			return false
+		}
+		ev := m.queue[0]
+		m.queue = m.queue[1:]
+		for _, t := range m.triggers {
+			if m.match(t, ev) {
+				m.current = append(m.current, t)
+			}
+		}
+	}
+	cmd := m.current[0].Commands[m.command]
+	m.command++
+	m.execute(cmd)
+	return true
+}
+
+// Run executes commands until the queue is empty.
+func (m *Manager) Run() {
+	for m.Step() {
+	}
+}
+
+func (m *Manager) execute(cmd config.Command) {
+	cmd, err := cmd.Expand(m.Properties)
+	if err == nil {
+		err = m.Executor.Execute(cmd)
+	}
+	if err != nil && m.OnError != nil {
+		m.OnError(cmd, err)
+	}
+}
+
+// match reports whether the event matches the trigger.
+func (m *Manager) match(t *config.Trigger, ev event) bool {
+	if !ev.property {
+		return t.Event() == ev.name && m.holds(t, "", "")
+	}
+	if t.Event() != "" {
+		return false
+	}
+	if ev.name == "" {
+		return m.holds(t, "", "")
+	}
+	for _, c := range t.Conditions {
+		if c.Property == ev.name {
+			return m.holds(t, ev.name, ev.value)
+		}
+	}
+	return false
+}
+
+// holds reports whether all property conditions of the trigger hold.  The named property
+// has the given value, and others are looked up in properties.  As in Android init, unset
+// properties are empty, and "*" matches any value of the changed property but only non-empty
+// values of others.
+func (m *Manager) holds(t *config.Trigger, name, value string) bool {
+	for _, c := range t.Conditions {
+		if !c.IsProperty() {
+			continue
+		}
+		if c.Property == name {
+			if !c.Match(value) {
+				return false
+			}
+			continue
+		}
+		val := m.property(c.Property)
+		if c.Value == "*" && val == "" || !c.Match(val) {
+			return false
+		}
+	}
+	return true
+}
+
+func (m *Manager) property(name string) string {
+	if m.Properties == nil {
+		return ""
+	}
+	val, _ := m.Properties.Property(name)
+	return val
+}
@@ -0,0 +1,214 @@
+package action
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/tie/x/config"
+	"github.com/tie/x/config/parser"
+)
+
+// fakeExecutor records executed commands.  The trigger and setprop commands queue events
+// and property changes as builtins would, and stop fails.
+type fakeExecutor struct {
+	m *Manager
+	props config.PropertyMap
+	log []string
+}
+
+func (x *fakeExecutor) Execute(cmd config.Command) error {
+	x.log = append(x.log, strings.Join(append([]string{cmd.Name}, cmd.Args...), " "))
+	switch cmd.Name {
+	case "trigger":
+		x.m.QueueEvent(cmd.Args[0])
+	case "setprop":
+		x.props[cmd.Args[0]] = cmd.Args[1]
+		x.m.QueuePropertyChange(cmd.Args[0], cmd.Args[1])
+	case "stop":
+		return errors.New("failed")
+	}
+	return nil
+}
+
+func newTestManager(t *testing.T, props config.PropertyMap, src ...string) (*Manager, *fakeExecutor) {
+	t.Helper()
+	m := &Manager{Properties: props}
+	x := &fakeExecutor{m: m, props: props}
+	m.Executor = x
+	for i, s := range src {
+		f, err := config.Parse("init"+string(rune('a'+i))+".rc", strings.NewReader(s), parser.AllErrors)
+		if err != nil {
+			t.Fatal(err)
+		}
+		m.Add(f)
+	}
+	return m, x
+}
+
+func expectLog(t *testing.T, x *fakeExecutor, want ...string) {
+	t.Helper()
+	if strings.Join(x.log, "\n") != strings.Join(want, "\n") {
+		t.Fatalf("expected commands\n%s\ngot\n%s", strings.Join(want, "\n"), strings.Join(x.log, "\n"))
+	}
+}
+
+func TestEventOrder(t *testing.T) {
+	m, x := newTestManager(t, config.PropertyMap{},
+		"on boot\n    start a1\n    start a2\non init\n    start b1\n",
+		"on boot\n    start c1\non early-init\n    start d1\n",
+	)
+	m.QueueEvent("early-init")
+	m.QueueEvent("init")
+	m.QueueEvent("boot")
+	m.QueueEvent("unknown")
+	m.Run()
+	expectLog(t, x, "start d1", "start b1", "start a1", "start a2", "start c1")
+	if m.Pending() {
+		t.Fatal("unexpected pending events")
+	}
+}
+
+func TestStep(t *testing.T) {
+	m, x := newTestManager(t, config.PropertyMap{},
+		"on boot\n    start a\n    trigger late\non late\n    start b\n",
+	)
+	if m.Step() {
+		t.Fatal("unexpected command without events")
+	}
+	m.QueueEvent("boot")
+	if !m.Pending() {
+		t.Fatal("expected pending event")
+	}
+	for i, want := range []string{"start a", "trigger late", "start b"} {
+		if !m.Step() {
+			t.Fatalf("expected command %d", i)
+		}
+		if got := x.log[len(x.log)-1]; got != want {
+			t.Fatalf("expected %q command, got %q", want, got)
+		}
+	}
+	if m.Step() || m.Pending() {
+		t.Fatal("unexpected pending commands")
+	}
+}
+
+func TestTriggerQueuesAfterPending(t *testing.T) {
+	// events queued by commands are processed after already queued events
+	m, x := newTestManager(t, config.PropertyMap{},
+		"on early-init\n    trigger late\n    start a\non init\n    start b\non late\n    start c\n",
+	)
+	m.QueueEvent("early-init")
+	m.QueueEvent("init")
+	m.Run()
+	expectLog(t, x, "trigger late", "start a", "start b", "start c")
+}
+
+func TestPropertyTriggers(t *testing.T) {
+	props := config.PropertyMap{"ro.debuggable": "1"}
+	m, x := newTestManager(t, props, strings.Join([]string{
+		"on property:sys.boot_completed=1",
+		"    start completed",
+		"on property:sys.boot_completed=1 && property:ro.debuggable=1",
+		"    write /debug ${sys.boot_completed}",
+		"on property:sys.usb.config=*",
+		"    write /usb ${sys.usb.config}",
+		"on boot && property:ro.debuggable=1",
+		"    start boot_debug",
+		"on boot && property:ro.debuggable=0",
+		"    start boot_user",
+		"on boot",
+		"    setprop sys.usb.config adb",
+		"    setprop sys.boot_completed 1",
+		"    setprop sys.boot_completed 0",
+	}, "\n"))
+	m.QueueEvent("boot")
+	m.Run()
+	expectLog(t, x,
+		"start boot_debug",
+		"setprop sys.usb.config adb",
+		"setprop sys.boot_completed 1",
+		"setprop sys.boot_completed 0",
+		"write /usb adb",
+		"start completed",
+		// the property is expanded at execution time
+		"write /debug 0",
+	)
+}
+
+func TestPropertyChangeOtherConditions(t *testing.T) {
+	props := config.PropertyMap{}
+	m, x := newTestManager(t, props, strings.Join([]string{
+		"on property:a=1 && property:b=*",
+		"    start ab",
+		"on property:a=1 && property:c=",
+		"    start ac",
+	}, "\n"))
+	props["a"] = "1"
+	m.QueuePropertyChange("a", "1")
+	m.Run()
+	// b is unset, so "*" does not match, and unset c is empty
+	expectLog(t, x, "start ac")
+	props["b"] = "x"
+	m.QueuePropertyChange("b", "x")
+	m.Run()
+	expectLog(t, x, "start ac", "start ab")
+	// changes of unrelated properties do not match
+	m.QueuePropertyChange("d", "1")
+	m.Run()
+	expectLog(t, x, "start ac", "start ab")
+}
+
+func TestQueueAllPropertyTriggers(t *testing.T) {
+	props := config.PropertyMap{"a": "1", "b": "2"}
+	m, x := newTestManager(t, props, strings.Join([]string{
+		"on property:a=1",
+		"    start a",
+		"on property:a=1 && property:b=1",
+		"    start ab",
+		"on property:b=*",
+		"    start b",
+		"on boot && property:a=1",
+		"    start boot",
+	}, "\n"))
+	m.QueueAllPropertyTriggers()
+	m.Run()
+	expectLog(t, x, "start a", "start b")
+}
+
+func TestErrors(t *testing.T) {
+	m, x := newTestManager(t, config.PropertyMap{},
+		"on boot\n    stop a\n    start ${unset}\n    start b\n",
+	)
+	var errs []string
+	m.OnError = func(cmd config.Command, err error) {
+		errs = append(errs, cmd.Name+": "+err.Error())
+	}
+	m.QueueEvent("boot")
+	m.Run()
+	// commands are executed after errors
+	expectLog(t, x, "stop a", "start b")
+	if len(errs) != 2 || errs[0] != "stop: failed" || !strings.Contains(errs[1], `property "unset" is not set`) {
+		t.Fatalf("unexpected %q errors", errs)
+	}
+}
+
+func TestExecutorFunc(t *testing.T) {
+	var names []string
+	m := &Manager{
+		Executor: ExecutorFunc(func(cmd config.Command) error {
+			names = append(names, cmd.Name)
+			return nil
+		}),
+	}
+	f, err := config.Parse("init.rc", strings.NewReader("on boot\non boot\n    start a\n"), parser.AllErrors)
+	if err != nil {
+		t.Fatal(err)
+	}
+	m.Add(f)
+	m.QueueEvent("boot")
+	m.Run()
+	if strings.Join(names, " ") != "start" {
+		t.Fatalf("unexpected %q commands", names)
+	}
+}
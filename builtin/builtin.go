@@ -0,0 +1,183 @@
+// Package builtin implements commands of trigger sections.
+//
+// Arguments are checked against the config schema before execution, so commands only
+// validate values that depend on the system, e.g. user names.  Paths are resolved in the
+// root directory of the executor, so commands may run against a directory other than the
+// system root.  Neither ".." nor symlinks lead out of the root directory.
+package builtin
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"syscall"
+
+	"github.com/tie/x/action"
+	"github.com/tie/x/config"
+	"github.com/tie/x/supervisor"
+)
+
+// Func executes a builtin command with checked arguments.
+type Func func(x *Executor, args []string) error
+
+// builtins maps every trigger command of the config schema to its implementation.  The
+// schema is the source of truth for command names and arguments, so the registry has an
+// entry for each schema command, and nil marks commands that are not supported.
+var builtins = map[string]Func{
+	"bootchart": nil,
+	"chmod": chmod,
+	"chown": chown,
+	"class_reset": nil,
+	"class_reset_post_data": nil,
+	"class_restart": nil,
+	"class_start": classStart,
+	"class_start_post_data": nil,
+	"class_stop": classStop,
+	"copy": copyFile,
+	"copy_per_line": nil,
+	"domainname": nil,
+	"enable": nil,
+	"enter_default_mount_ns": nil,
+	"exec": execCommand,
+	"exec_background": nil,
+	"exec_start": nil,
+	"export": export,
+	"hostname": hostname,
+	"ifup": nil,
+	"init_user0": nil,
+	"insmod": nil,
+	"installkey": nil,
+	"interface_restart": nil,
+	"interface_start": nil,
+	"interface_stop": nil,
+	"load_exports": nil,
+	"load_persist_props": nil,
+	"load_system_props": nil,
+	"loglevel": nil,
+	"mark_post_data": nil,
+	"mkdir": mkdir,
+	"mount": nil,
+	"mount_all": nil,
+	"perform_apex_config": nil,
+	"readahead": nil,
+	"remount_userdata": nil,
+	"restart": restart,
+	"restorecon": nil,
+	"restorecon_recursive": nil,
+	"rm": rm,
+	"rmdir": rmdir,
+	"setprop": setprop,
+	"setrlimit": nil,
+	"start": start,
+	"stop": stop,
+	"swapon_all": nil,
+	"symlink": symlink,
+	"sysclktz": nil,
+	"trigger": trigger,
+	"umount": nil,
+	"umount_all": nil,
+	"update_linker_config": nil,
+	"verity_update_state": nil,
+	"wait": wait,
+	"wait_for_prop": nil,
+	"write": write,
+}
+
+// Commands returns names of supported builtin commands in sorted order.
+func Commands() []string {
+	var names []string
+	for name, fn := range builtins {
+		if fn != nil {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Executor executes builtin commands.  It implements action.Executor.
+type Executor struct {
+	// Root is the directory absolute paths are resolved in.  Empty means "/".
+	Root string
+	// Properties are system properties set by setprop.
+	Properties config.PropertyMap
+	// Supervisor runs services for service and exec commands.
+	Supervisor *supervisor.Supervisor
+	// Actions queues events and property changes.  May be nil.
+	Actions *action.Manager
+}
+
+var _ action.Executor = (*Executor)(nil)
+
+// Execute executes the builtin command.  Unsupported commands and arguments not matching
+// the schema are errors.
+func (x *Executor) Execute(cmd config.Command) error {
+	fn := builtins[cmd.Name]
+	if fn == nil {
+		return fmt.Errorf("%s: command is not supported", cmd.Name)
+	}
+	if err := config.CheckCommand(cmd); err != nil {
+		return fmt.Errorf("%s: %w", cmd.Name, err)
+	}
+	if err := fn(x, cmd.Args); err != nil {
+		return fmt.Errorf("%s: %w", cmd.Name, err)
+	}
+	return nil
+}
+
+// inRoot calls fn with the root directory and the absolute path relative to the root.  Paths
+// can not escape the root with "..", and errors of fn are reported as errors of the
+// operation on the absolute path.
+func (x *Executor) inRoot(op, name string, fn func(r *os.Root, name string) error) error {
+	root := x.Root
+	if root == "" {
+		root = "/"
+	}
+	r, err := os.OpenRoot(root)
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+	name = path.Clean("/" + name)
+	rel := "."
+	if name != "/" {
+		rel = filepath.FromSlash(name[1:])
+	}
+	err = fn(r, rel)
+	switch e := err.(type) {
+	case nil:
+		return nil
+	case *os.PathError:
+		err = e.Err
+	case *os.LinkError:
+		err = e.Err
+	case syscall.Errno:
+	default:
+		return err
+	}
+	return &os.PathError{Op: op, Path: name, Err: err}
+}
+
+// supervisor returns the supervisor, or an error if there is none.
+func (x *Executor) supervisor() (*supervisor.Supervisor, error) {
+	if x.Supervisor == nil {
+		return nil, fmt.Errorf("no supervisor")
+	}
+	return x.Supervisor, nil
+}
+
+// writeFile writes the file in the root like Android init does: without following symlinks
+// and creating it with 0600 permissions.
+func writeFile(r *os.Root, name string, data []byte) error {
+	f, err := r.OpenFile(name, os.O_WRONLY|os.O_CREATE|os.O_TRUNC|syscall.O_NOFOLLOW, 0600)
+	if err != nil {
+		return err
+	}
+	_, err = f.Write(data)
+	if err1 := f.Close(); err == nil {
+		err = err1
+	}
+	return err
+}
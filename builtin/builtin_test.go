@@ -0,0 +1,342 @@
+package builtin
+
+import (
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/tie/x/action"
+	"github.com/tie/x/config"
+	"github.com/tie/x/config/parser"
+	"github.com/tie/x/supervisor"
+)
+
+// newTestExecutor returns an executor with a temporary root directory.
+func newTestExecutor(t *testing.T) *Executor {
+	root := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(root, "proc/sys/kernel"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	x := &Executor{
+		Root: root,
+		Properties: config.PropertyMap{},
+		Supervisor: supervisor.New(supervisor.Options{Env: []string{"PATH=/bin:/usr/bin"}}),
+	}
+	t.Cleanup(x.Supervisor.StopAll)
+	return x
+}
+
+// run executes the config on the boot event with a new action queue and returns errors of
+// commands.
+func run(t *testing.T, x *Executor, lines ...string) []string {
+	t.Helper()
+	src := strings.Join(lines, "\n") + "\n"
+	f, err := config.Parse("init.rc", strings.NewReader(src), parser.AllErrors)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var errs []string
+	x.Actions = &action.Manager{
+		Executor: x,
+		Properties: x.Properties,
+		OnError: func(cmd config.Command, err error) {
+			errs = append(errs, err.Error())
+		},
+	}
+	x.Actions.Add(f)
+	x.Actions.QueueEvent("boot")
+	x.Actions.Run()
+	return errs
+}
+
+func expectNoErrors(t *testing.T, errs []string) {
+	t.Helper()
+	if len(errs) != 0 {
+		t.Fatalf("unexpected errors:\n%s", strings.Join(errs, "\n"))
+	}
+}
+
+func expectFile(t *testing.T, x *Executor, name, content string, mode os.FileMode) {
+	t.Helper()
+	path := filepath.Join(x.Root, name)
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != content {
+		t.Fatalf("expected %q in %s, got %q", content, name, data)
+	}
+	info, _ := os.Stat(path)
+	if info.Mode().Perm() != mode {
+		t.Fatalf("expected %s mode of %s, got %s", mode, name, info.Mode().Perm())
+	}
+}
+
+func expectMode(t *testing.T, x *Executor, name string, mode os.FileMode) {
+	t.Helper()
+	info, err := os.Lstat(filepath.Join(x.Root, name))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Mode() != mode {
+		t.Fatalf("expected %s mode of %s, got %s", mode, name, info.Mode())
+	}
+}
+
+func expectNotExist(t *testing.T, x *Executor, name string) {
+	t.Helper()
+	if _, err := os.Lstat(filepath.Join(x.Root, name)); !os.IsNotExist(err) {
+		t.Fatalf("expected %s to not exist, got %v", name, err)
+	}
+}
+
+func TestFileCommands(t *testing.T) {
+	x := newTestExecutor(t)
+	owner := strconv.Itoa(os.Getuid()) + " " + strconv.Itoa(os.Getgid())
+	errs := run(t, x,
+		"on boot",
+		"    mkdir /data 0750 "+owner,
+		"    mkdir /data/a",
+		"    mkdir /data/keep 0700",
+		"    mkdir /data/keep",
+		"    write /data/a/f hello",
+		"    copy /data/a/f /data/a/g",
+		"    chmod 0640 /data/a/g",
+		"    chown "+owner+" /data/a/g",
+		"    symlink /data/a/f /data/link",
+		"    write /data/a/rm x",
+		"    rm /data/a/rm",
+		"    mkdir /data/b",
+		"    rmdir /data/b",
+		"    write /../../escape x",
+		"    hostname android",
+		"    wait /data/a/f",
+	)
+	expectNoErrors(t, errs)
+	expectMode(t, x, "data", os.ModeDir|0750)
+	expectMode(t, x, "data/a", os.ModeDir|0755)
+	expectMode(t, x, "data/keep", os.ModeDir|0700)
+	expectFile(t, x, "data/a/f", "hello", 0600)
+	expectFile(t, x, "data/a/g", "hello", 0640)
+	expectFile(t, x, "escape", "x", 0600)
+	expectFile(t, x, "proc/sys/kernel/hostname", "android", 0600)
+	if target, err := os.Readlink(filepath.Join(x.Root, "data/link")); err != nil || target != "/data/a/f" {
+		t.Fatalf("expected /data/a/f link, got %q, %v", target, err)
+	}
+	expectNotExist(t, x, "data/a/rm")
+	expectNotExist(t, x, "data/b")
+}
+
+func TestFileCommandErrors(t *testing.T) {
+	x := newTestExecutor(t)
+	errs := run(t, x,
+		"on boot",
+		"    rm /missing",
+		"    rmdir /missing",
+		"    write /missing/f x",
+		"    wait /missing 0",
+		"    write /f x",
+		"    mkdir /f",
+		"    chown nosuchuser /f",
+	)
+	want := []string{
+		"rm: unlink /missing: no such file or directory",
+		"rmdir: rmdir /missing: no such file or directory",
+		"write: open /missing/f: no such file or directory",
+		"wait: timed out waiting for /missing",
+		"mkdir: mkdir /f: file exists",
+		"chown: user: unknown user nosuchuser",
+	}
+	if strings.Join(errs, "\n") != strings.Join(want, "\n") {
+		t.Fatalf("expected errors\n%s\ngot\n%s", strings.Join(want, "\n"), strings.Join(errs, "\n"))
+	}
+}
+
+func TestFileCommandsEscape(t *testing.T) {
+	x := newTestExecutor(t)
+	outside := t.TempDir()
+	if err := os.WriteFile(filepath.Join(outside, "f"), nil, 0600); err != nil {
+		t.Fatal(err)
+	}
+	rel, err := filepath.Rel(x.Root, outside)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink(outside, filepath.Join(x.Root, "etc")); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink(rel, filepath.Join(x.Root, "up")); err != nil {
+		t.Fatal(err)
+	}
+	owner := strconv.Itoa(os.Getuid()) + " " + strconv.Itoa(os.Getgid())
+	errs := run(t, x,
+		"on boot",
+		"    write /etc/passwd x",
+		"    mkdir /up/dir",
+		"    copy /etc/f /f",
+		"    write /f x",
+		"    symlink /f /link",
+		"    chmod 0644 /link",
+		"    chown "+owner+" /link",
+	)
+	want := []string{
+		"write: open /etc/passwd: path escapes from parent",
+		"mkdir: mkdir /up/dir: path escapes from parent",
+		"copy: open /etc/f: path escapes from parent",
+		"chmod: chmod /link: too many levels of symbolic links",
+	}
+	if strings.Join(errs, "\n") != strings.Join(want, "\n") {
+		t.Fatalf("expected errors\n%s\ngot\n%s", strings.Join(want, "\n"), strings.Join(errs, "\n"))
+	}
+	for _, name := range []string{"passwd", "dir"} {
+		if _, err := os.Lstat(filepath.Join(outside, name)); !os.IsNotExist(err) {
+			t.Fatalf("expected %s to not exist outside of the root, got %v", name, err)
+		}
+	}
+	expectFile(t, x, "f", "x", 0600)
+}
+
+func TestWait(t *testing.T) {
+	x := newTestExecutor(t)
+	go func() {
+		time.Sleep(50 * time.Millisecond)
+		os.WriteFile(filepath.Join(x.Root, "ready"), nil, 0644)
+	}()
+	expectNoErrors(t, run(t, x, "on boot", "    wait /ready 5"))
+}
+
+func TestPropertyCommands(t *testing.T) {
+	x := newTestExecutor(t)
+	errs := run(t, x,
+		"on boot",
+		"    setprop sys.a 1",
+		"    setprop ro.b 2",
+		"    setprop ro.b 3",
+		"    trigger late",
+		"on property:sys.a=1",
+		"    write /a ${sys.a}",
+		"on late",
+		"    write /late ${ro.b}",
+		"    mount tmpfs tmpfs /mnt",
+	)
+	want := []string{
+		"setprop: property ro.b is read-only",
+		"mount: command is not supported",
+	}
+	if strings.Join(errs, "\n") != strings.Join(want, "\n") {
+		t.Fatalf("expected errors\n%s\ngot\n%s", strings.Join(want, "\n"), strings.Join(errs, "\n"))
+	}
+	expectFile(t, x, "a", "1", 0600)
+	expectFile(t, x, "late", "2", 0600)
+}
+
+func TestServiceCommands(t *testing.T) {
+	x := newTestExecutor(t)
+	services := []*supervisor.Service{
+		{Name: "a", Classes: []string{"main"}},
+		{Name: "b", Classes: []string{"main"}, Disabled: true},
+		{Name: "c", Classes: []string{"core"}},
+	}
+	for _, svc := range services {
+		svc.Path = "/bin/sh"
+		svc.Args = []string{"-c", "exec sleep 60"}
+		x.Supervisor.Add(svc)
+	}
+	state := func(name string) supervisor.State {
+		st, _ := x.Supervisor.Status(name)
+		return st.State
+	}
+	expectNoErrors(t, run(t, x,
+		"on boot",
+		"    class_start main",
+		"    restart --only-if-running c",
+	))
+	if state("a") != supervisor.Running || state("b") != supervisor.Stopped || state("c") != supervisor.Stopped {
+		t.Fatalf("unexpected states %s %s %s", state("a"), state("b"), state("c"))
+	}
+	expectNoErrors(t, run(t, x,
+		"on boot",
+		"    start b",
+		"    class_stop main",
+		"    restart c",
+	))
+	if state("a") == supervisor.Running || state("b") == supervisor.Running || state("c") != supervisor.Running {
+		t.Fatalf("unexpected states %s %s %s", state("a"), state("b"), state("c"))
+	}
+	errs := run(t, x,
+		"on boot",
+		"    stop c",
+		"    start d",
+	)
+	if len(errs) != 1 || errs[0] != "start: service d not found" {
+		t.Fatalf("unexpected %q errors", errs)
+	}
+}
+
+func TestExec(t *testing.T) {
+	x := newTestExecutor(t)
+	out := filepath.Join(x.Root, "out")
+	errs := run(t, x,
+		"on boot",
+		"    export A 1",
+		"    exec -- /bin/sh -c \"echo $A > "+out+"\"",
+		"    exec u:r:init:s0 -- /bin/sh -c \"exit 3\"",
+		"    exec -- /nonexistent",
+	)
+	want := []string{
+		"exec: /bin/sh exited with status 3",
+		"exec: run exec /nonexistent: fork/exec /nonexistent: no such file or directory",
+	}
+	if strings.Join(errs, "\n") != strings.Join(want, "\n") {
+		t.Fatalf("expected errors\n%s\ngot\n%s", strings.Join(want, "\n"), strings.Join(errs, "\n"))
+	}
+	data, err := os.ReadFile(out)
+	if err != nil || string(data) != "1\n" {
+		t.Fatalf("expected exported variable, got %q, %v", data, err)
+	}
+}
+
+func TestExecuteArguments(t *testing.T) {
+	x := newTestExecutor(t)
+	cases := []struct {
+		Command config.Command
+		Error string
+	}{
+		{config.Command{Name: "write"}, "write: expected 2 arguments, got 0, usage: write <path> <content>"},
+		{config.Command{Name: "exec", Args: []string{"/bin/true"}}, "exec: expected at least 2 arguments, got 1, usage: exec [ <seclabel> [ <user> [ <group> ]* ] ] -- <command> [ <argument> ]*"},
+		{config.Command{Name: "exec", Args: []string{"/bin/true", "x"}}, "exec: expected -- before command, usage: exec [ <seclabel> [ <user> [ <group> ]* ] ] -- <command> [ <argument> ]*"},
+		{config.Command{Name: "chmod", Args: []string{"rw", "/f"}}, "chmod: argument 1: expected octal permissions, got \"rw\""},
+		{config.Command{Name: "mount", Args: []string{"tmpfs"}}, "mount: command is not supported"},
+		{config.Command{Name: "frobnicate"}, "frobnicate: command is not supported"},
+	}
+	for _, c := range cases {
+		err := x.Execute(c.Command)
+		if err == nil || err.Error() != c.Error {
+			t.Errorf("expected %q error, got %v", c.Error, err)
+		}
+	}
+	for _, args := range [][]string{nil, {"/bin/true"}} {
+		if err := execCommand(x, args); err == nil || err.Error() != "expected -- before command" {
+			t.Errorf("%q: expected missing separator error, got %v", args, err)
+		}
+	}
+	if err := execCommand(x, []string{"u:r:init:s0", "--"}); err == nil || err.Error() != "expected command after --" {
+		t.Errorf("expected missing command error, got %v", err)
+	}
+}
+
+func TestRegistry(t *testing.T) {
+	for _, name := range config.Directives("on") {
+		if _, ok := builtins[name]; !ok {
+			t.Errorf("trigger command %s is not registered", name)
+		}
+	}
+	for name := range builtins {
+		if _, ok := config.Usage("on", name); !ok {
+			t.Errorf("builtin %s is not a trigger command", name)
+		}
+	}
+}
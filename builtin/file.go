@@ -0,0 +1,213 @@
+package builtin
+
+import (
+	"fmt"
+	"os"
+	"os/user"
+	"strconv"
+	"syscall"
+	"time"
+)
+
+// waitInterval is the interval of checking for the file in wait.
+const waitInterval = 10 * time.Millisecond
+
+// defaultWaitTimeout is the timeout of wait without an explicit one.
+const defaultWaitTimeout = 5 * time.Second
+
+// mkdir <path> [ <mode> ] [ <owner> ] [ <group> ] [ encryption=<action> ] [ key=<key> ]
+//
+// The default mode is 0755.  Existing directories are not an error, and their mode and
+// owner are updated if given.  Encryption options are ignored.
+func mkdir(x *Executor, args []string) error {
+	mode := uint64(0755)
+	if len(args) > 1 {
+		mode, _ = strconv.ParseUint(args[1], 8, 32)
+	}
+	var uid, gid int
+	if len(args) > 2 {
+		var err error
+		uid, gid, err = lookupOwner(args[2:min(len(args), 4)])
+		if err != nil {
+			return err
+		}
+	}
+	return x.inRoot("mkdir", args[0], func(r *os.Root, name string) error {
+		created := true
+		if err := r.Mkdir(name, os.FileMode(mode)); err != nil {
+			info, serr := r.Lstat(name)
+			if serr != nil || !info.IsDir() {
+				return err
+			}
+			created = false
+		}
+		// mode of the new directory is affected by umask
+		if created || len(args) > 1 {
+			if err := r.Chmod(name, os.FileMode(mode)); err != nil {
+				return err
+			}
+		}
+		if len(args) < 3 {
+			return nil
+		}
+		return r.Lchown(name, uid, gid)
+	})
+}
+
+// chmod <octal-mode> <path>
+//
+// Symlinks are not followed.
+func chmod(x *Executor, args []string) error {
+	mode, _ := strconv.ParseUint(args[0], 8, 32)
+	return x.inRoot("chmod", args[1], func(r *os.Root, name string) error {
+		info, err := r.Lstat(name)
+		if err != nil {
+			return err
+		}
+		if info.Mode()&os.ModeSymlink != 0 {
+			return syscall.ELOOP
+		}
+		return r.Chmod(name, os.FileMode(mode))
+	})
+}
+
+// chown <owner> [ <group> ] <path>
+//
+// Symlinks are not followed.
+func chown(x *Executor, args []string) error {
+	uid, gid, err := lookupOwner(args[:len(args)-1])
+	if err != nil {
+		return err
+	}
+	return x.inRoot("chown", args[len(args)-1], func(r *os.Root, name string) error {
+		return r.Lchown(name, uid, gid)
+	})
+}
+
+// write <path> <content>
+func write(x *Executor, args []string) error {
+	return x.inRoot("open", args[0], func(r *os.Root, name string) error {
+		return writeFile(r, name, []byte(args[1]))
+	})
+}
+
+// copy <src> <dst>
+func copyFile(x *Executor, args []string) error {
+	var data []byte
+	err := x.inRoot("open", args[0], func(r *os.Root, name string) error {
+		var err error
+		data, err = r.ReadFile(name)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	return x.inRoot("open", args[1], func(r *os.Root, name string) error {
+		return writeFile(r, name, data)
+	})
+}
+
+// symlink <target> <path>
+//
+// The target is not resolved in the root directory.
+func symlink(x *Executor, args []string) error {
+	return x.inRoot("symlink", args[1], func(r *os.Root, name string) error {
+		return r.Symlink(args[0], name)
+	})
+}
+
+// rm <path>
+func rm(x *Executor, args []string) error {
+	return x.inRoot("unlink", args[0], func(r *os.Root, name string) error {
+		info, err := r.Lstat(name)
+		if err != nil {
+			return err
+		}
+		if info.IsDir() {
+			return syscall.EISDIR
+		}
+		return r.Remove(name)
+	})
+}
+
+// rmdir <path>
+func rmdir(x *Executor, args []string) error {
+	return x.inRoot("rmdir", args[0], func(r *os.Root, name string) error {
+		info, err := r.Lstat(name)
+		if err != nil {
+			return err
+		}
+		if !info.IsDir() {
+			return syscall.ENOTDIR
+		}
+		return r.Remove(name)
+	})
+}
+
+// hostname <name>
+func hostname(x *Executor, args []string) error {
+	return x.inRoot("open", "/proc/sys/kernel/hostname", func(r *os.Root, name string) error {
+		return writeFile(r, name, []byte(args[0]))
+	})
+}
+
+// wait <path> [ <timeout> ]
+func wait(x *Executor, args []string) error {
+	timeout := defaultWaitTimeout
+	if len(args) > 1 {
+		seconds, _ := strconv.Atoi(args[1])
+		timeout = time.Duration(seconds) * time.Second
+	}
+	deadline := time.Now().Add(timeout)
+	return x.inRoot("lstat", args[0], func(r *os.Root, name string) error {
+		for {
+			_, err := r.Lstat(name)
+			if err == nil {
+				return nil
+			}
+			if !os.IsNotExist(err) {
+				return err
+			}
+			if !time.Now().Before(deadline) {
+				return fmt.Errorf("timed out waiting for %s", args[0])
+			}
+			time.Sleep(waitInterval)
+		}
+	})
+}
+
+// lookupOwner returns IDs of the owner and the optional group.  The group is -1 if it is
+// not given, i.e. it is not changed.
+func lookupOwner(args []string) (uid, gid int, err error) {
+	uid, err = lookupID(args[0], false)
+	if err != nil {
+		return 0, 0, err
+	}
+	gid = -1
+	if len(args) > 1 {
+		gid, err = lookupID(args[1], true)
+	}
+	return uid, gid, err
+}
+
+// lookupID returns the numeric ID of the user or group name.  Numeric names are IDs.
+func lookupID(name string, group bool) (int, error) {
+	if id, err := strconv.Atoi(name); err == nil {
+		return id, nil
+	}
+	var id string
+	if group {
+		g, err := user.LookupGroup(name)
+		if err != nil {
+			return 0, err
+		}
+		id = g.Gid
+	} else {
+		u, err := user.Lookup(name)
+		if err != nil {
+			return 0, err
+		}
+		id = u.Uid
+	}
+	return strconv.Atoi(id)
+}
@@ -0,0 +1,46 @@
+package builtin
+
+import (
+	"fmt"
+	"strings"
+)
+
+// setprop <name> <value>
+//
+// Properties with "ro." prefix are read-only once set.  Changes are queued for property
+// triggers.
+func setprop(x *Executor, args []string) error {
+	name, value := args[0], args[1]
+	if x.Properties == nil {
+		return fmt.Errorf("no properties")
+	}
+	if _, ok := x.Properties[name]; ok && strings.HasPrefix(name, "ro.") {
+		return fmt.Errorf("property %s is read-only", name)
+	}
+	x.Properties[name] = value
+	if x.Actions != nil {
+		x.Actions.QueuePropertyChange(name, value)
+	}
+	return nil
+}
+
+// export <name> <value>
+//
+// The variable is set in the environment of services started afterwards.
+func export(x *Executor, args []string) error {
+	s, err := x.supervisor()
+	if err != nil {
+		return err
+	}
+	s.Setenv(args[0], args[1])
+	return nil
+}
+
+// trigger <event>
+func trigger(x *Executor, args []string) error {
+	if x.Actions == nil {
+		return fmt.Errorf("no action queue")
+	}
+	x.Actions.QueueEvent(args[0])
+	return nil
+}
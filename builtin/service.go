@@ -0,0 +1,151 @@
+package builtin
+
+import (
+	"fmt"
+	"strings"
+	"syscall"
+
+	"github.com/tie/x/supervisor"
+)
+
+// start <service>
+func start(x *Executor, args []string) error {
+	s, err := x.supervisor()
+	if err != nil {
+		return err
+	}
+	return s.Start(args[0])
+}
+
+// stop <service>
+func stop(x *Executor, args []string) error {
+	s, err := x.supervisor()
+	if err != nil {
+		return err
+	}
+	return s.Stop(args[0])
+}
+
+// restart [ --only-if-running ] <service>
+func restart(x *Executor, args []string) error {
+	s, err := x.supervisor()
+	if err != nil {
+		return err
+	}
+	name := args[len(args)-1]
+	if len(args) == 2 {
+		st, err := s.Status(name)
+		if err != nil {
+			return err
+		}
+		if st.State != supervisor.Running {
+			return nil
+		}
+	}
+	return s.Restart(name)
+}
+
+// class_start <serviceclass>
+//
+// Disabled services are not started.
+func classStart(x *Executor, args []string) error {
+	return forClass(x, args[0], func(s *supervisor.Supervisor, svc *supervisor.Service) error {
+		if svc.Disabled {
+			return nil
+		}
+		return s.Start(svc.Name)
+	})
+}
+
+// class_stop <serviceclass>
+func classStop(x *Executor, args []string) error {
+	return forClass(x, args[0], func(s *supervisor.Supervisor, svc *supervisor.Service) error {
+		return s.Stop(svc.Name)
+	})
+}
+
+// forClass calls fn for services of the class.  It returns the first error.
+func forClass(x *Executor, class string, fn func(s *supervisor.Supervisor, svc *supervisor.Service) error) error {
+	s, err := x.supervisor()
+	if err != nil {
+		return err
+	}
+	var first error
+	for _, name := range s.Services() {
+		svc, err := s.Service(name)
+		if err != nil || !svc.HasClass(class) {
+			continue
+		}
+		if err := fn(s, svc); err != nil && first == nil {
+			first = err
+		}
+	}
+	return first
+}
+
+// exec [ <seclabel> [ <user> [ <group> ]* ] ] -- <command> [ <argument> ]*
+//
+// The command runs to completion, and exit with non-zero status is an error.  The security
+// label is ignored.
+func execCommand(x *Executor, args []string) error {
+	s, err := x.supervisor()
+	if err != nil {
+		return err
+	}
+	sep := 0
+	for sep < len(args) && args[sep] != "--" {
+		sep++
+	}
+	switch sep {
+	case len(args):
+		return fmt.Errorf("expected -- before command")
+	case len(args) - 1:
+		return fmt.Errorf("expected command after --")
+	}
+	opts, argv := args[:sep], args[sep+1:]
+	svc := &supervisor.Service{
+		Name: "exec " + strings.Join(argv, " "),
+		Path: argv[0],
+		Args: argv[1:],
+	}
+	if len(opts) > 1 {
+		cred, err := credential(opts[1:])
+		if err != nil {
+			return err
+		}
+		svc.Credential = cred
+	}
+	ws, err := s.Run(svc)
+	if err != nil {
+		return err
+	}
+	switch {
+	case ws.Signaled():
+		return fmt.Errorf("%s killed by %s", argv[0], ws.Signal())
+	case ws.ExitStatus() != 0:
+		return fmt.Errorf("%s exited with status %d", argv[0], ws.ExitStatus())
+	}
+	return nil
+}
+
+// credential returns the credential of the user and groups.  The first group is the primary
+// group, which defaults to the numeric ID of the user.
+func credential(args []string) (*syscall.Credential, error) {
+	uid, err := lookupID(args[0], false)
+	if err != nil {
+		return nil, err
+	}
+	cred := &syscall.Credential{Uid: uint32(uid), Gid: uint32(uid)}
+	for i, name := range args[1:] {
+		gid, err := lookupID(name, true)
+		if err != nil {
+			return nil, err
+		}
+		if i == 0 {
+			cred.Gid = uint32(gid)
+			continue
+		}
+		cred.Groups = append(cred.Groups, uint32(gid))
+	}
+	return cred, nil
+}
@@ -0,0 +1,310 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/tie/x/config"
+	"github.com/tie/x/config/format"
+	"github.com/tie/x/config/parser"
+	"github.com/tie/x/config/token"
+)
+
+// serviceCommands are trigger commands whose last argument is a service name.
+var serviceCommands = map[string]bool{
+	"enable": true,
+	"exec_start": true,
+	"restart": true,
+	"start": true,
+	"stop": true,
+}
+
+// statementAt describes the statement at some offset of a document.
+type statementAt struct {
+	// section is the directive of the enclosing section header, empty at top level
+	section string
+	stmt parser.Statement
+	// arg is the index of the statement token at the offset
+	arg int
+}
+
+// locate returns the statement with a text token at the offset.  Offsets at the end of
+// a token are considered to be in the token.
+func locate(tree *parser.Tree, offset int) (statementAt, bool) {
+	for i, nodes := range tree.Sections {
+		section := ""
+		if i > 0 {
+			section = nodes[0].Statement().Directive()
+		}
+		for _, n := range nodes {
+			stmt := n.Statement()
+			for k, tok := range stmt {
+				if tok.Pos.Offset <= offset && offset <= tok.End.Offset {
+					return statementAt{section: section, stmt: stmt, arg: k}, true
+				}
+			}
+		}
+	}
+	return statementAt{}, false
+}
+
+// sectionAt returns the directive of the section header preceding the offset.
+func sectionAt(tree *parser.Tree, offset int) string {
+	section := ""
+	for _, nodes := range tree.Sections[1:] {
+		header := nodes[0].Statement()
+		if header[0].Pos.Offset > offset {
+			break
+		}
+		section = header.Directive()
+	}
+	return section
+}
+
+func (s *server) publishDiagnostics(d *document) error {
+	src := d.doc.Source()
+	unit, errs := d.doc.Unit()
+	if err := config.Validate([]*config.File{config.Build(d.path, unit)}); err != nil {
+		errs = append(errs, err.(token.ErrorList)...)
+	}
+	errs.Sort()
+	t := newText(src)
+	diags := []Diagnostic{}
+	for _, e := range errs {
+		end := e.Pos.Offset
+		for end < len(src) && !isSpace(src[end]) {
+			end++
+		}
+		diags = append(diags, Diagnostic{
+			Range: t.rangeOf(e.Pos.Offset, end),
+			Severity: severityError,
+			Source: "init",
+			Message: e.Message(),
+		})
+	}
+	return s.conn.notify("textDocument/publishDiagnostics", &PublishDiagnosticsParams{
+		URI: d.uri,
+		Diagnostics: diags,
+	})
+}
+
+func (s *server) hover(params *TextDocumentPositionParams) (*Hover, error) {
+	d, err := s.document(params.TextDocument.URI)
+	if err != nil {
+		return nil, err
+	}
+	t := newText(d.doc.Source())
+	at, ok := locate(d.doc.Tree(), t.offset(params.Position))
+	if !ok || at.arg != 0 {
+		return nil, nil
+	}
+	usage, ok := config.Usage(at.section, at.stmt.Directive())
+	if !ok {
+		return nil, nil
+	}
+	tok := at.stmt[0]
+	r := t.rangeOf(tok.Pos.Offset, tok.End.Offset)
+	return &Hover{
+		Contents: MarkupContent{
+			Kind: "markdown",
+			Value: "```\n" + usage + "\n```",
+		},
+		Range: &r,
+	}, nil
+}
+
+func (s *server) completion(params *TextDocumentPositionParams) ([]CompletionItem, error) {
+	d, err := s.document(params.TextDocument.URI)
+	if err != nil {
+		return nil, err
+	}
+	src := d.doc.Source()
+	t := newText(src)
+	offset := t.offset(params.Position)
+	// only directives are completed, i.e. the first word of a line; the line is derived
+	// from the offset, since positions past the end of the document are clamped
+	line := src[t.lines[t.position(offset).Line]:offset]
+	word := bytes.TrimLeft(line, " \t")
+	if bytes.ContainsAny(word, " \t") {
+		return []CompletionItem{}, nil
+	}
+	items := []CompletionItem{}
+	section := sectionAt(d.doc.Tree(), offset)
+	for _, dir := range config.Directives(section) {
+		kind := completionFunction
+		if section == "service" {
+			kind = completionProperty
+		}
+		usage, _ := config.Usage(section, dir)
+		items = append(items, CompletionItem{Label: dir, Kind: kind, Detail: usage})
+	}
+	// sections may start at the first column only
+	if len(word) == len(line) {
+		var keywords []string
+		for keyword := range config.Syntax.Sections {
+			keywords = append(keywords, keyword)
+		}
+		sort.Strings(keywords)
+		for _, keyword := range keywords {
+			usage, _ := config.Usage(keyword, keyword)
+			items = append(items, CompletionItem{Label: keyword, Kind: completionKeyword, Detail: usage})
+		}
+	}
+	return items, nil
+}
+
+func (s *server) definition(params *TextDocumentPositionParams) ([]Location, error) {
+	d, err := s.document(params.TextDocument.URI)
+	if err != nil {
+		return nil, err
+	}
+	t := newText(d.doc.Source())
+	at, ok := locate(d.doc.Tree(), t.offset(params.Position))
+	if !ok || at.arg == 0 {
+		return []Location{}, nil
+	}
+	args, err := at.stmt.Text()
+	if err != nil {
+		return []Location{}, nil
+	}
+	switch {
+	case at.section == "import" && args[0] == "import":
+		return s.importLocations(d.path, args[at.arg]), nil
+	case at.section == "on" && serviceCommands[args[0]] && at.arg == len(args)-1:
+		return s.serviceLocations(d, args[at.arg]), nil
+	}
+	return []Location{}, nil
+}
+
+// importLocations returns locations of files imported with the path.  Property references
+// are not expanded, so paths with them have no locations.
+func (s *server) importLocations(importer, path string) []Location {
+	locs := []Location{}
+	path, err := config.Expand(path, nil)
+	if err != nil {
+		return locs
+	}
+	if strings.HasPrefix(path, "/") {
+		path = filepath.Join(s.root, filepath.FromSlash(path))
+	} else {
+		path = filepath.Join(filepath.Dir(importer), filepath.FromSlash(path))
+	}
+	matches, err := filepath.Glob(path)
+	if err != nil {
+		return locs
+	}
+	for _, match := range matches {
+		info, err := os.Stat(match)
+		if err != nil {
+			continue
+		}
+		if !info.IsDir() {
+			locs = append(locs, Location{URI: pathURI(match)})
+			continue
+		}
+		files, _ := filepath.Glob(filepath.Join(match, "*.rc"))
+		for _, file := range files {
+			locs = append(locs, Location{URI: pathURI(file)})
+		}
+	}
+	return locs
+}
+
+// serviceLocations returns locations of the named service definitions in the document,
+// files it imports and other open documents.
+func (s *server) serviceLocations(d *document, name string) []Location {
+	locs := []Location{}
+	loaded := map[string]bool{}
+	if rel, ok := s.rootRel(d.path); ok {
+		overlay := &overlayFS{
+			FS: os.DirFS(s.root),
+			files: map[string][]byte{},
+		}
+		for _, doc := range s.docs {
+			if rel, ok := s.rootRel(doc.path); ok {
+				overlay.files[rel] = doc.doc.Source()
+			}
+		}
+		l := &config.Loader{
+			FS: overlay,
+			Mode: parser.AllErrors,
+		}
+		files, _ := l.Load(rel)
+		for _, f := range files {
+			path := filepath.Join(s.root, filepath.FromSlash(f.Name))
+			loaded[path] = true
+			locs = append(locs, s.services(path, f, name)...)
+		}
+	}
+	for _, doc := range s.openDocuments(d) {
+		if loaded[doc.path] {
+			continue
+		}
+		unit, _ := doc.doc.Unit()
+		locs = append(locs, s.services(doc.path, config.Build(doc.path, unit), name)...)
+	}
+	return locs
+}
+
+// services returns locations of the named service definitions in the file at path.
+func (s *server) services(path string, f *config.File, name string) []Location {
+	var locs []Location
+	for _, svc := range f.Services {
+		if svc.Name != name {
+			continue
+		}
+		src, err := s.source(path)
+		if err != nil {
+			continue
+		}
+		t := newText(src)
+		locs = append(locs, Location{URI: pathURI(path), Range: t.rangeOf(svc.Pos.Offset, svc.Pos.Offset)})
+	}
+	return locs
+}
+
+// source returns contents of the file, from an open document if there is one.
+func (s *server) source(path string) ([]byte, error) {
+	for _, doc := range s.docs {
+		if doc.path == path {
+			return doc.doc.Source(), nil
+		}
+	}
+	return os.ReadFile(path)
+}
+
+// rootRel returns the slash-separated path relative to the root if the path is in the root.
+func (s *server) rootRel(path string) (string, bool) {
+	rel, err := filepath.Rel(s.root, path)
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return "", false
+	}
+	return filepath.ToSlash(rel), true
+}
+
+func (s *server) formatting(params *DocumentFormattingParams) ([]TextEdit, error) {
+	d, err := s.document(params.TextDocument.URI)
+	if err != nil {
+		return nil, err
+	}
+	src := d.doc.Source()
+	out, err := format.Source(src)
+	if err != nil {
+		return nil, err
+	}
+	if bytes.Equal(src, out) {
+		return []TextEdit{}, nil
+	}
+	t := newText(src)
+	return []TextEdit{{
+		Range: t.rangeOf(0, len(src)),
+		NewText: string(out),
+	}}, nil
+}
+
+func isSpace(c byte) bool {
+	return c == ' ' || c == '\t' || c == '\r' || c == '\n'
+}
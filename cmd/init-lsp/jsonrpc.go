@@ -0,0 +1,129 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/textproto"
+	"strconv"
+)
+
+// JSON-RPC error codes.
+const (
+	codeParseError = -32700
+	codeInvalidRequest = -32600
+	codeMethodNotFound = -32601
+	codeInvalidParams = -32602
+	codeServerNotInitialized = -32002
+)
+
+// request is a JSON-RPC request or notification.  Notifications have no ID.
+type request struct {
+	JSONRPC string `json:"jsonrpc"`
+	ID *json.RawMessage `json:"id,omitempty"`
+	Method string `json:"method"`
+	Params json.RawMessage `json:"params,omitempty"`
+}
+
+// response is a JSON-RPC response.  Result is set unless Error is.
+type response struct {
+	JSONRPC string `json:"jsonrpc"`
+	ID *json.RawMessage `json:"id"`
+	Result *json.RawMessage `json:"result,omitempty"`
+	Error *rpcError `json:"error,omitempty"`
+}
+
+type rpcError struct {
+	Code int `json:"code"`
+	Message string `json:"message"`
+}
+
+func (e *rpcError) Error() string {
+	return e.Message
+}
+
+func errorf(code int, format string, args ...interface{}) *rpcError {
+	return &rpcError{
+		Code: code,
+		Message: fmt.Sprintf(format, args...),
+	}
+}
+
+// conn reads and writes messages with Content-Length framing.
+type conn struct {
+	r *textproto.Reader
+	w io.Writer
+}
+
+func newConn(r io.Reader, w io.Writer) *conn {
+	return &conn{
+		r: textproto.NewReader(bufio.NewReader(r)),
+		w: w,
+	}
+}
+
+// read reads the next message.
+func (c *conn) read() ([]byte, error) {
+	header, err := c.r.ReadMIMEHeader()
+	if err != nil {
+		return nil, err
+	}
+	n, err := strconv.Atoi(header.Get("Content-Length"))
+	if err != nil || n < 0 {
+		return nil, fmt.Errorf("invalid Content-Length %q", header.Get("Content-Length"))
+	}
+	body := make([]byte, n)
+	if _, err := io.ReadFull(c.r.R, body); err != nil {
+		return nil, err
+	}
+	return body, nil
+}
+
+// write writes the message marshaled as JSON.
+func (c *conn) write(msg interface{}) error {
+	body, err := json.Marshal(msg)
+	if err != nil {
+		return err
+	}
+	if _, err := fmt.Fprintf(c.w, "Content-Length: %d\r\n\r\n", len(body)); err != nil {
+		return err
+	}
+	_, err = c.w.Write(body)
+	return err
+}
+
+// reply writes the response to the request with the result or error.
+func (c *conn) reply(id *json.RawMessage, result interface{}, err error) error {
+	resp := &response{
+		JSONRPC: "2.0",
+		ID: id,
+	}
+	if err != nil {
+		rerr, ok := err.(*rpcError)
+		if !ok {
+			rerr = errorf(codeInvalidRequest, "%s", err)
+		}
+		resp.Error = rerr
+		return c.write(resp)
+	}
+	raw, merr := json.Marshal(result)
+	if merr != nil {
+		return merr
+	}
+	resp.Result = (*json.RawMessage)(&raw)
+	return c.write(resp)
+}
+
+// notify writes a notification.
+func (c *conn) notify(method string, params interface{}) error {
+	raw, err := json.Marshal(params)
+	if err != nil {
+		return err
+	}
+	return c.write(&request{
+		JSONRPC: "2.0",
+		Method: method,
+		Params: raw,
+	})
+}
@@ -0,0 +1,40 @@
+// Command init-lsp is a language server for Android init config files.  It speaks the
+// Language Server Protocol over standard input and output.
+//
+// Supported features are diagnostics, hover documentation of directives, completion of
+// directives, go to definition of imported files and of services in start and stop
+// commands, and document formatting.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+)
+
+func main() {
+	log.SetPrefix("init-lsp: ")
+	log.SetFlags(0)
+	fset := flag.NewFlagSet("init-lsp", flag.ContinueOnError)
+	root := fset.String("root", "/", "root `directory` for absolute import paths")
+	fset.Usage = func() {
+		fmt.Fprintln(fset.Output(), "usage: init-lsp [flags]")
+		fset.PrintDefaults()
+	}
+	if err := fset.Parse(os.Args[1:]); err != nil {
+		os.Exit(2)
+	}
+	if fset.NArg() > 0 {
+		fset.Usage()
+		os.Exit(2)
+	}
+	rootDir, err := filepath.Abs(*root)
+	if err != nil {
+		log.Print(err)
+		os.Exit(2)
+	}
+	s := newServer(newConn(os.Stdin, os.Stdout), rootDir)
+	os.Exit(s.serve())
+}
@@ -0,0 +1,42 @@
+package main
+
+import (
+	"io/fs"
+	"path"
+	"time"
+)
+
+// overlayFS is a file system with contents of open documents overlaid on top of the
+// underlying file system, so that imports are loaded with unsaved changes.  Only ReadFile
+// and Stat are overlaid, which is enough for config.Loader.
+type overlayFS struct {
+	fs.FS
+	// files are contents of overlaid files by name
+	files map[string][]byte
+}
+
+func (o *overlayFS) ReadFile(name string) ([]byte, error) {
+	if data, ok := o.files[name]; ok {
+		return data, nil
+	}
+	return fs.ReadFile(o.FS, name)
+}
+
+func (o *overlayFS) Stat(name string) (fs.FileInfo, error) {
+	if data, ok := o.files[name]; ok {
+		return &overlayInfo{name: path.Base(name), size: int64(len(data))}, nil
+	}
+	return fs.Stat(o.FS, name)
+}
+
+type overlayInfo struct {
+	name string
+	size int64
+}
+
+func (fi *overlayInfo) Name() string { return fi.name }
+func (fi *overlayInfo) Size() int64 { return fi.size }
+func (fi *overlayInfo) Mode() fs.FileMode { return 0o444 }
+func (fi *overlayInfo) ModTime() time.Time { return time.Time{} }
+func (fi *overlayInfo) IsDir() bool { return false }
+func (fi *overlayInfo) Sys() interface{} { return nil }
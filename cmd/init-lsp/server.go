@@ -0,0 +1,217 @@
+package main
+
+import (
+	"encoding/json"
+	"io"
+	"log"
+	"sort"
+
+	"github.com/tie/x/config"
+	"github.com/tie/x/config/parser"
+)
+
+// server is the state of a language server session.
+type server struct {
+	conn *conn
+	// root is the directory of absolute import paths
+	root string
+	docs map[string]*document
+	initialized bool
+	shutdown bool
+}
+
+// document is an open text document.
+type document struct {
+	uri string
+	path string
+	doc *parser.Document
+}
+
+func newServer(c *conn, root string) *server {
+	return &server{
+		conn: c,
+		root: root,
+		docs: map[string]*document{},
+	}
+}
+
+// serve handles messages until exit notification or end of input.  It returns the exit code.
+func (s *server) serve() int {
+	for {
+		body, err := s.conn.read()
+		if err == io.EOF {
+			return 1
+		}
+		if err != nil {
+			log.Print(err)
+			return 1
+		}
+		var req request
+		if err := json.Unmarshal(body, &req); err != nil {
+			s.conn.reply(nil, nil, errorf(codeParseError, "%s", err))
+			continue
+		}
+		if req.Method == "exit" {
+			if s.shutdown {
+				return 0
+			}
+			return 1
+		}
+		result, err := s.handle(&req)
+		if req.ID == nil {
+			// notification
+			if err != nil {
+				log.Printf("%s: %s", req.Method, err)
+			}
+			continue
+		}
+		if err := s.conn.reply(req.ID, result, err); err != nil {
+			log.Print(err)
+			return 1
+		}
+	}
+}
+
+// handle dispatches the request to the method handler.
+func (s *server) handle(req *request) (interface{}, error) {
+	if !s.initialized && req.Method != "initialize" {
+		return nil, errorf(codeServerNotInitialized, "server not initialized")
+	}
+	switch req.Method {
+	case "initialize":
+		s.initialized = true
+		return &InitializeResult{
+			Capabilities: ServerCapabilities{
+				TextDocumentSync: syncIncremental,
+				HoverProvider: true,
+				CompletionProvider: &CompletionOptions{},
+				DefinitionProvider: true,
+				DocumentFormattingProvider: true,
+			},
+			ServerInfo: ServerInfo{Name: "init-lsp"},
+		}, nil
+	case "initialized":
+		return nil, nil
+	case "shutdown":
+		s.shutdown = true
+		return nil, nil
+	case "textDocument/didOpen":
+		var params DidOpenTextDocumentParams
+		if err := unmarshalParams(req, &params); err != nil {
+			return nil, err
+		}
+		return nil, s.didOpen(&params)
+	case "textDocument/didChange":
+		var params DidChangeTextDocumentParams
+		if err := unmarshalParams(req, &params); err != nil {
+			return nil, err
+		}
+		return nil, s.didChange(&params)
+	case "textDocument/didClose":
+		var params DidCloseTextDocumentParams
+		if err := unmarshalParams(req, &params); err != nil {
+			return nil, err
+		}
+		return nil, s.didClose(&params)
+	case "textDocument/hover":
+		var params TextDocumentPositionParams
+		if err := unmarshalParams(req, &params); err != nil {
+			return nil, err
+		}
+		return s.hover(&params)
+	case "textDocument/completion":
+		var params TextDocumentPositionParams
+		if err := unmarshalParams(req, &params); err != nil {
+			return nil, err
+		}
+		return s.completion(&params)
+	case "textDocument/definition":
+		var params TextDocumentPositionParams
+		if err := unmarshalParams(req, &params); err != nil {
+			return nil, err
+		}
+		return s.definition(&params)
+	case "textDocument/formatting":
+		var params DocumentFormattingParams
+		if err := unmarshalParams(req, &params); err != nil {
+			return nil, err
+		}
+		return s.formatting(&params)
+	}
+	return nil, errorf(codeMethodNotFound, "method %q not found", req.Method)
+}
+
+func unmarshalParams(req *request, params interface{}) error {
+	if err := json.Unmarshal(req.Params, params); err != nil {
+		return errorf(codeInvalidParams, "%s: %s", req.Method, err)
+	}
+	return nil
+}
+
+func (s *server) document(uri string) (*document, error) {
+	d, ok := s.docs[uri]
+	if !ok {
+		return nil, errorf(codeInvalidParams, "document %s is not open", uri)
+	}
+	return d, nil
+}
+
+func (s *server) didOpen(params *DidOpenTextDocumentParams) error {
+	item := params.TextDocument
+	path := uriPath(item.URI)
+	d := &document{
+		uri: item.URI,
+		path: path,
+		doc: parser.NewDocument(path, []byte(item.Text), config.Syntax),
+	}
+	s.docs[item.URI] = d
+	return s.publishDiagnostics(d)
+}
+
+func (s *server) didChange(params *DidChangeTextDocumentParams) error {
+	d, err := s.document(params.TextDocument.URI)
+	if err != nil {
+		return err
+	}
+	for _, change := range params.ContentChanges {
+		if change.Range == nil {
+			d.doc = parser.NewDocument(d.path, []byte(change.Text), config.Syntax)
+			continue
+		}
+		t := newText(d.doc.Source())
+		edit := parser.Edit{
+			Start: t.offset(change.Range.Start),
+			End: t.offset(change.Range.End),
+			Text: change.Text,
+		}
+		if err := d.doc.Update(edit); err != nil {
+			return err
+		}
+	}
+	return s.publishDiagnostics(d)
+}
+
+func (s *server) didClose(params *DidCloseTextDocumentParams) error {
+	uri := params.TextDocument.URI
+	delete(s.docs, uri)
+	return s.conn.notify("textDocument/publishDiagnostics", &PublishDiagnosticsParams{
+		URI: uri,
+		Diagnostics: []Diagnostic{},
+	})
+}
+
+// openDocuments returns open documents with the given one first, and the rest ordered by URI.
+func (s *server) openDocuments(first *document) []*document {
+	docs := []*document{first}
+	var uris []string
+	for uri := range s.docs {
+		if uri != first.uri {
+			uris = append(uris, uri)
+		}
+	}
+	sort.Strings(uris)
+	for _, uri := range uris {
+		docs = append(docs, s.docs[uri])
+	}
+	return docs
+}
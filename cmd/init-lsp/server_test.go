@@ -0,0 +1,391 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/tie/x/config"
+)
+
+// client is the test side of a language server session over in-memory pipes.
+type client struct {
+	t *testing.T
+	conn *conn
+	id int
+}
+
+// newTestClient starts a server with the root directory and returns an initialized client.
+func newTestClient(t *testing.T, root string) *client {
+	inR, inW := io.Pipe()
+	outR, outW := io.Pipe()
+	s := newServer(newConn(inR, outW), root)
+	done := make(chan int, 1)
+	go func() {
+		done <- s.serve()
+		outW.Close()
+	}()
+	t.Cleanup(func() {
+		inW.Close()
+		go io.Copy(io.Discard, outR)
+		<-done
+	})
+	c := &client{t: t, conn: newConn(outR, inW)}
+	var res InitializeResult
+	if err := c.call("initialize", &InitializeParams{}, &res); err != nil {
+		t.Fatal(err)
+	}
+	if res.ServerInfo.Name != "init-lsp" {
+		t.Fatalf("unexpected %+v initialize result", res)
+	}
+	c.notify("initialized", struct{}{})
+	return c
+}
+
+// call sends the request and decodes the result of the response.  Response errors are
+// returned as *rpcError.
+func (c *client) call(method string, params, result interface{}) error {
+	c.t.Helper()
+	c.id++
+	raw, _ := json.Marshal(params)
+	id := json.RawMessage(strconv.Itoa(c.id))
+	if err := c.conn.write(&request{JSONRPC: "2.0", ID: &id, Method: method, Params: raw}); err != nil {
+		c.t.Fatal(err)
+	}
+	var resp response
+	c.read(&resp)
+	if resp.ID == nil || string(*resp.ID) != string(id) {
+		c.t.Fatalf("expected response to %s, got %+v", id, resp)
+	}
+	if resp.Error != nil {
+		return resp.Error
+	}
+	if resp.Result != nil && result != nil {
+		if err := json.Unmarshal(*resp.Result, result); err != nil {
+			c.t.Fatal(err)
+		}
+	}
+	return nil
+}
+
+// notify sends the notification.
+func (c *client) notify(method string, params interface{}) {
+	c.t.Helper()
+	if err := c.conn.notify(method, params); err != nil {
+		c.t.Fatal(err)
+	}
+}
+
+// read reads the next message from the server.
+func (c *client) read(msg interface{}) {
+	c.t.Helper()
+	body, err := c.conn.read()
+	if err != nil {
+		c.t.Fatal(err)
+	}
+	if err := json.Unmarshal(body, msg); err != nil {
+		c.t.Fatal(err)
+	}
+}
+
+// diagnostics reads the next diagnostics notification.
+func (c *client) diagnostics() PublishDiagnosticsParams {
+	c.t.Helper()
+	var req request
+	c.read(&req)
+	if req.Method != "textDocument/publishDiagnostics" {
+		c.t.Fatalf("expected diagnostics, got %s notification", req.Method)
+	}
+	var params PublishDiagnosticsParams
+	if err := json.Unmarshal(req.Params, &params); err != nil {
+		c.t.Fatal(err)
+	}
+	return params
+}
+
+// open opens the document and returns its diagnostics.
+func (c *client) open(uri, text string) []Diagnostic {
+	c.t.Helper()
+	c.notify("textDocument/didOpen", &DidOpenTextDocumentParams{
+		TextDocument: TextDocumentItem{URI: uri, LanguageID: "init", Text: text},
+	})
+	return c.diagnostics().Diagnostics
+}
+
+func position(line, char int) Position {
+	return Position{Line: line, Character: char}
+}
+
+func span(line, start, end int) Range {
+	return Range{Start: position(line, start), End: position(line, end)}
+}
+
+func TestConn(t *testing.T) {
+	var b bytes.Buffer
+	c := newConn(&b, &b)
+	if err := c.notify("a", []int{1}); err != nil {
+		t.Fatal(err)
+	}
+	want := "Content-Length: 43\r\n\r\n{\"jsonrpc\":\"2.0\",\"method\":\"a\",\"params\":[1]}"
+	if b.String() != want {
+		t.Fatalf("expected %q, got %q", want, b.String())
+	}
+	body, err := c.read()
+	if err != nil || string(body) != want[strings.Index(want, "{"):] {
+		t.Fatalf("unexpected %q body, %v", body, err)
+	}
+	cases := []string{
+		"Content-Length: x\r\n\r\n{}",
+		"Content-Length: -1\r\n\r\n",
+		"Content-Length: 10\r\n\r\n{}",
+	}
+	for _, input := range cases {
+		if body, err := newConn(strings.NewReader(input), io.Discard).read(); err == nil {
+			t.Errorf("%q: expected error, got %q", input, body)
+		}
+	}
+}
+
+func TestRequestErrors(t *testing.T) {
+	inR, inW := io.Pipe()
+	outR, outW := io.Pipe()
+	s := newServer(newConn(inR, outW), "/")
+	done := make(chan int, 1)
+	go func() {
+		done <- s.serve()
+	}()
+	c := &client{t: t, conn: newConn(outR, inW)}
+	err := c.call("textDocument/hover", &TextDocumentPositionParams{}, nil)
+	if e, ok := err.(*rpcError); !ok || e.Code != codeServerNotInitialized {
+		t.Fatalf("expected not initialized error, got %v", err)
+	}
+	c.call("initialize", &InitializeParams{}, nil)
+	cases := []struct {
+		method string
+		params interface{}
+		code int
+	}{
+		{"unknown", nil, codeMethodNotFound},
+		{"textDocument/hover", []int{}, codeInvalidParams},
+		{"textDocument/hover", &TextDocumentPositionParams{TextDocument: TextDocumentIdentifier{URI: "file:///x.rc"}}, codeInvalidParams},
+	}
+	for _, tc := range cases {
+		err := c.call(tc.method, tc.params, nil)
+		if e, ok := err.(*rpcError); !ok || e.Code != tc.code {
+			t.Errorf("%s: expected error code %d, got %v", tc.method, tc.code, err)
+		}
+	}
+	// malformed messages are replied with a parse error
+	body := "{"
+	io.WriteString(inW, "Content-Length: 1\r\n\r\n"+body)
+	var resp response
+	c.read(&resp)
+	if resp.Error == nil || resp.Error.Code != codeParseError {
+		t.Fatalf("expected parse error, got %+v", resp)
+	}
+	c.call("shutdown", nil, nil)
+	go io.Copy(io.Discard, outR)
+	c.notify("exit", nil)
+	if code := <-done; code != 0 {
+		t.Fatalf("expected exit code 0, got %d", code)
+	}
+}
+
+func TestDiagnostics(t *testing.T) {
+	c := newTestClient(t, t.TempDir())
+	diags := c.open("file:///init.rc", strings.Join([]string{
+		"on boot",
+		"    strat a",
+		"service a /bin/a",
+		"    user",
+		"service a /bin/b",
+	}, "\n"))
+	want := []Diagnostic{
+		{Range: span(1, 4, 9), Severity: severityError, Source: "init", Message: "unknown command \"strat\", did you mean \"start\"?"},
+		{Range: span(3, 4, 8), Severity: severityError, Source: "init", Message: "user: expected 1 arguments, got 0, usage: user <username>"},
+		{Range: span(4, 0, 7), Severity: severityError, Source: "init", Message: "service \"a\" redefined, previous definition at /init.rc:3:1"},
+	}
+	if !reflect.DeepEqual(diags, want) {
+		t.Fatalf("expected diagnostics\n%+v\ngot\n%+v", want, diags)
+	}
+	c.notify("textDocument/didChange", &DidChangeTextDocumentParams{
+		TextDocument: TextDocumentIdentifier{URI: "file:///init.rc"},
+		ContentChanges: []TextDocumentContentChangeEvent{
+			{Range: &Range{Start: position(1, 6), End: position(1, 8)}, Text: "ar"},
+			{Range: &Range{Start: position(3, 8), End: position(3, 8)}, Text: " root"},
+			{Range: &Range{Start: position(4, 8), End: position(4, 9)}, Text: "b"},
+		},
+	})
+	if diags := c.diagnostics().Diagnostics; len(diags) != 0 {
+		t.Fatalf("unexpected diagnostics %+v", diags)
+	}
+	c.notify("textDocument/didClose", &DidCloseTextDocumentParams{
+		TextDocument: TextDocumentIdentifier{URI: "file:///init.rc"},
+	})
+	if d := c.diagnostics(); d.URI != "file:///init.rc" || len(d.Diagnostics) != 0 {
+		t.Fatalf("unexpected diagnostics %+v", d)
+	}
+}
+
+func TestHover(t *testing.T) {
+	c := newTestClient(t, t.TempDir())
+	c.open("file:///init.rc", "on boot\n    start a\nservice a /bin/a\n    user root\n")
+	usage := func(section, dir string) string {
+		s, _ := config.Usage(section, dir)
+		return "```\n" + s + "\n```"
+	}
+	cases := []struct {
+		pos Position
+		value string
+		rng Range
+	}{
+		{position(1, 6), usage("on", "start"), span(1, 4, 9)},
+		{position(1, 9), usage("on", "start"), span(1, 4, 9)},
+		{position(3, 4), usage("service", "user"), span(3, 4, 8)},
+		{position(2, 2), usage("service", "service"), span(2, 0, 7)},
+		{position(1, 10), "", Range{}},
+		{position(1, 2), "", Range{}},
+		{position(100, 0), "", Range{}},
+	}
+	for _, tc := range cases {
+		var hover *Hover
+		err := c.call("textDocument/hover", &TextDocumentPositionParams{
+			TextDocument: TextDocumentIdentifier{URI: "file:///init.rc"},
+			Position: tc.pos,
+		}, &hover)
+		if err != nil {
+			t.Fatalf("%+v: unexpected %s error", tc.pos, err)
+		}
+		if tc.value == "" {
+			if hover != nil {
+				t.Errorf("%+v: unexpected %+v hover", tc.pos, hover)
+			}
+			continue
+		}
+		if hover == nil || hover.Contents.Value != tc.value || hover.Range == nil || *hover.Range != tc.rng {
+			t.Errorf("%+v: expected %q hover at %+v, got %+v", tc.pos, tc.value, tc.rng, hover)
+		}
+	}
+}
+
+func TestCompletion(t *testing.T) {
+	c := newTestClient(t, t.TempDir())
+	c.open("file:///init.rc", "on boot\n    st\nservice a /bin/a\n    \n    user root")
+	labels := func(items []CompletionItem) string {
+		var names []string
+		for _, item := range items {
+			names = append(names, item.Label)
+		}
+		return strings.Join(names, " ")
+	}
+	var sections []CompletionItem
+	for _, keyword := range []string{"import", "on", "service"} {
+		sections = append(sections, CompletionItem{Label: keyword})
+	}
+	trigger := strings.Join(config.Directives("on"), " ")
+	service := strings.Join(config.Directives("service"), " ")
+	cases := []struct {
+		pos Position
+		labels string
+	}{
+		{position(1, 6), trigger},
+		{position(3, 4), service},
+		{position(4, 6), service},
+		{position(4, 10), ""},
+		{position(0, 0), trigger + " " + labels(sections)},
+		{position(2, 3), service + " " + labels(sections)},
+		{position(100, 0), ""},
+		{position(100, 100), ""},
+		{position(-1, 0), trigger + " " + labels(sections)},
+	}
+	for _, tc := range cases {
+		var items []CompletionItem
+		err := c.call("textDocument/completion", &TextDocumentPositionParams{
+			TextDocument: TextDocumentIdentifier{URI: "file:///init.rc"},
+			Position: tc.pos,
+		}, &items)
+		if err != nil {
+			t.Fatalf("%+v: unexpected %s error", tc.pos, err)
+		}
+		if got := labels(items); got != tc.labels {
+			t.Errorf("%+v: expected %q completions, got %q", tc.pos, tc.labels, got)
+		}
+	}
+}
+
+func TestDefinition(t *testing.T) {
+	root := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(root, "etc/init"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(root, "etc/init/a.rc"), []byte("# a\nservice a /bin/a\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	c := newTestClient(t, root)
+	uri := pathURI(filepath.Join(root, "init.rc"))
+	c.open(uri, "import /etc/init/a.rc\non boot\n    start a\n    stop b\n    start c\nservice b /bin/b\n")
+	c.open("file:///other.rc", "service c /bin/c\n")
+	aURI := pathURI(filepath.Join(root, "etc/init/a.rc"))
+	cases := []struct {
+		pos Position
+		locs []Location
+	}{
+		{position(0, 10), []Location{{URI: aURI}}},
+		{position(2, 10), []Location{{URI: aURI, Range: span(1, 0, 0)}}},
+		{position(3, 9), []Location{{URI: uri, Range: span(5, 0, 0)}}},
+		{position(4, 10), []Location{{URI: "file:///other.rc"}}},
+		{position(2, 5), []Location{}},
+		{position(5, 9), []Location{}},
+	}
+	for _, tc := range cases {
+		var locs []Location
+		err := c.call("textDocument/definition", &TextDocumentPositionParams{
+			TextDocument: TextDocumentIdentifier{URI: uri},
+			Position: tc.pos,
+		}, &locs)
+		if err != nil {
+			t.Fatalf("%+v: unexpected %s error", tc.pos, err)
+		}
+		if !reflect.DeepEqual(locs, tc.locs) {
+			t.Errorf("%+v: expected %+v locations, got %+v", tc.pos, tc.locs, locs)
+		}
+	}
+}
+
+func TestFormatting(t *testing.T) {
+	c := newTestClient(t, t.TempDir())
+	cases := []struct {
+		text string
+		edits []TextEdit
+	}{
+		{"on boot\n    start a\n", []TextEdit{}},
+		{"on  boot\n start   a\n\n\n", []TextEdit{{Range: Range{End: position(4, 0)}, NewText: "on boot\n    start a\n"}}},
+	}
+	for i, tc := range cases {
+		uri := "file:///" + string(rune('a'+i)) + ".rc"
+		c.open(uri, tc.text)
+		var edits []TextEdit
+		err := c.call("textDocument/formatting", &DocumentFormattingParams{
+			TextDocument: TextDocumentIdentifier{URI: uri},
+		}, &edits)
+		if err != nil {
+			t.Fatalf("%q: unexpected %s error", tc.text, err)
+		}
+		if !reflect.DeepEqual(edits, tc.edits) {
+			t.Errorf("%q: expected %+v edits, got %+v", tc.text, tc.edits, edits)
+		}
+	}
+	c.open("file:///bad.rc", "on \"boot\n")
+	err := c.call("textDocument/formatting", &DocumentFormattingParams{
+		TextDocument: TextDocumentIdentifier{URI: "file:///bad.rc"},
+	}, nil)
+	if err == nil {
+		t.Fatal("expected formatting error")
+	}
+}
@@ -0,0 +1,95 @@
+package main
+
+import (
+	"net/url"
+	"path/filepath"
+	"strings"
+	"unicode/utf16"
+	"unicode/utf8"
+)
+
+// text converts between byte offsets in the source and LSP positions.
+type text struct {
+	src []byte
+	// lines are offsets of line starts
+	lines []int
+}
+
+func newText(src []byte) *text {
+	t := &text{
+		src: src,
+		lines: []int{0},
+	}
+	for i, c := range src {
+		if c == '\n' {
+			t.lines = append(t.lines, i+1)
+		}
+	}
+	return t
+}
+
+// position returns the position of the byte offset.
+func (t *text) position(offset int) Position {
+	if offset > len(t.src) {
+		offset = len(t.src)
+	}
+	line := len(t.lines) - 1
+	for line > 0 && t.lines[line] > offset {
+		line--
+	}
+	char := 0
+	for _, r := range string(t.src[t.lines[line]:offset]) {
+		char += utf16Len(r)
+	}
+	return Position{Line: line, Character: char}
+}
+
+// offset returns the byte offset of the position.  Positions past the end of line are
+// clamped to the line end.
+func (t *text) offset(pos Position) int {
+	if pos.Line >= len(t.lines) {
+		return len(t.src)
+	}
+	if pos.Line < 0 {
+		return 0
+	}
+	offset := t.lines[pos.Line]
+	for char := 0; char < pos.Character && offset < len(t.src); {
+		r, size := utf8.DecodeRune(t.src[offset:])
+		if r == '\n' {
+			break
+		}
+		char += utf16Len(r)
+		offset += size
+	}
+	return offset
+}
+
+func (t *text) rangeOf(start, end int) Range {
+	return Range{Start: t.position(start), End: t.position(end)}
+}
+
+func utf16Len(r rune) int {
+	if utf16.IsSurrogate(r) || r < 0x10000 {
+		return 1
+	}
+	return 2
+}
+
+// uriPath returns the file path of the file URI.
+func uriPath(uri string) string {
+	u, err := url.Parse(uri)
+	if err != nil || u.Scheme != "file" {
+		return uri
+	}
+	return filepath.FromSlash(u.Path)
+}
+
+// pathURI returns the file URI of the path.
+func pathURI(path string) string {
+	u := url.URL{Scheme: "file", Path: filepath.ToSlash(path)}
+	if !strings.HasPrefix(u.Path, "/") {
+		u.Path = "/" + u.Path
+	}
+	return u.String()
+}
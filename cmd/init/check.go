@@ -0,0 +1,125 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/tie/x/config"
+	"github.com/tie/x/config/parser"
+)
+
+// check implements the check command.  It returns the exit code: 0 if all files are valid,
+// 1 if there are errors and 2 on usage errors.
+func check(args []string) int {
+	fset := flag.NewFlagSet("check", flag.ContinueOnError)
+	root := fset.String("root", "/", "root `directory` for absolute import paths")
+	props := config.PropertyMap{}
+	fset.Var(propertyFlag(props), "p", "set property for import path expansion, in `name=value` format")
+	fset.Usage = func() {
+		fmt.Fprintln(fset.Output(), "usage: init check [flags] files...")
+		fset.PrintDefaults()
+	}
+	if err := fset.Parse(args); err != nil {
+		return 2
+	}
+	if fset.NArg() == 0 {
+		fset.Usage()
+		return 2
+	}
+	rootDir, err := filepath.Abs(*root)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 2
+	}
+	l := &config.Loader{
+		FS: os.DirFS(rootDir),
+		Properties: props,
+		Mode: parser.AllErrors,
+	}
+	paths := checkPaths{root: rootDir, args: map[string]string{}}
+	paths.wd, _ = os.Getwd()
+	status := 0
+	var names []string
+	for _, arg := range fset.Args() {
+		name, err := paths.name(arg)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			status = 1
+			continue
+		}
+		if _, ok := paths.args[name]; !ok {
+			paths.args[name] = arg
+		}
+		names = append(names, name)
+	}
+	// files are loaded together, so that files named several times or also imported
+	// by other named files are loaded once
+	files, err := l.Load(names...)
+	if err != nil {
+		if e, ok := err.(*fs.PathError); ok {
+			e.Path = paths.display(e.Path)
+		}
+		printErrors(err, paths.display)
+		status = 1
+	}
+	if err := config.Validate(files); err != nil {
+		printErrors(err, paths.display)
+		status = 1
+	}
+	return status
+}
+
+// checkPaths converts between file paths and names in the root file system.
+type checkPaths struct {
+	root, wd string
+	// args maps names of files to paths given on the command line.
+	args map[string]string
+}
+
+// name returns file system name of the file path.
+func (p checkPaths) name(path string) (string, error) {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return "", err
+	}
+	rel, err := filepath.Rel(p.root, abs)
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return "", fmt.Errorf("%s: file is outside of root directory %s", path, p.root)
+	}
+	return filepath.ToSlash(rel), nil
+}
+
+// display returns file path of the file system name for diagnostics.  Files named on the
+// command line are displayed as given, others relative to the working directory if possible.
+func (p checkPaths) display(name string) string {
+	if arg, ok := p.args[name]; ok {
+		return arg
+	}
+	abs := filepath.Join(p.root, filepath.FromSlash(name))
+	if p.wd != "" {
+		if rel, err := filepath.Rel(p.wd, abs); err == nil && !strings.HasPrefix(rel, "..") {
+			return rel
+		}
+	}
+	return abs
+}
+
+// propertyFlag is a flag.Value that adds name=value pairs to the map.
+type propertyFlag config.PropertyMap
+
+func (f propertyFlag) String() string {
+	return ""
+}
+
+func (f propertyFlag) Set(s string) error {
+	i := strings.IndexByte(s, '=')
+	if i <= 0 {
+		return fmt.Errorf("expected name=value, got %q", s)
+	}
+	f[s[:i]] = s[i+1:]
+	return nil
+}
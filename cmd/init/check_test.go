@@ -0,0 +1,84 @@
+package main
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// captureStderr returns the standard error output of fn.
+func captureStderr(t *testing.T, fn func()) string {
+	t.Helper()
+	return capture(t, &os.Stderr, fn)
+}
+
+// capture returns the output written to the file by fn.
+func capture(t *testing.T, file **os.File, fn func()) string {
+	t.Helper()
+	f, err := os.CreateTemp(t.TempDir(), "output")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	saved := *file
+	*file = f
+	defer func() { *file = saved }()
+	fn()
+	f.Seek(0, io.SeekStart)
+	out, err := io.ReadAll(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(out)
+}
+
+func TestCheck(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"init.rc": "import etc/a.rc\non boot\n    start a\n",
+		"etc/a.rc": "service a /bin/a\n",
+		"etc/bad.rc": "service b /bin/b\n    user\n",
+		"etc/dup.rc": "service a /bin/a\n",
+	}
+	for name, data := range files {
+		path := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(data), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	t.Chdir(dir)
+	abs := func(name string) string {
+		return filepath.Join(dir, filepath.FromSlash(name))
+	}
+	cases := []struct {
+		args []string
+		status int
+		out string
+	}{
+		{[]string{"init.rc", "etc/a.rc"}, 0, ""},
+		{[]string{"etc/a.rc", "./etc/a.rc", abs("etc/a.rc")}, 0, ""},
+		{[]string{abs("init.rc"), "etc/a.rc"}, 0, ""},
+		{[]string{abs("etc/bad.rc")}, 1, abs("etc/bad.rc") + ":2:5: user: expected 1 arguments, got 0, usage: user <username>\n"},
+		{[]string{"etc/bad.rc"}, 1, "etc/bad.rc:2:5: user: expected 1 arguments, got 0, usage: user <username>\n"},
+		{[]string{abs("missing.rc")}, 1, "open " + abs("missing.rc") + ": no such file or directory\n"},
+		{[]string{"../outside.rc"}, 1, "../outside.rc: file is outside of root directory " + dir + "\n"},
+		{[]string{abs("etc/a.rc"), "etc/dup.rc"}, 1, "etc/dup.rc:1:1: service \"a\" redefined, previous definition at " + abs("etc/a.rc") + ":1:1\n"},
+		{[]string{"init.rc", "etc/dup.rc"}, 1, "etc/dup.rc:1:1: service \"a\" redefined, previous definition at etc/a.rc:1:1\n"},
+	}
+	for _, c := range cases {
+		t.Run(strings.Join(c.args, " "), func(t *testing.T) {
+			var status int
+			out := captureStderr(t, func() {
+				status = check(append([]string{"-root", dir}, c.args...))
+			})
+			if status != c.status || out != c.out {
+				t.Fatalf("expected status %d and output\n%s\ngot status %d and output\n%s", c.status, c.out, status, out)
+			}
+		})
+	}
+}
@@ -0,0 +1,182 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+)
+
+// diffContext is the number of unchanged lines around changes in diff hunks.
+const diffContext = 3
+
+type editOp byte
+
+const (
+	editEqual editOp = ' '
+	editDelete editOp = '-'
+	editInsert editOp = '+'
+)
+
+// edit is a single line of the edit script.  A and B are line indices in the old and new text.
+type edit struct {
+	Op editOp
+	A, B int
+}
+
+// unifiedDiff returns a unified diff from a to b, or nil if the texts are equal.
+func unifiedDiff(aName, bName string, a, b []byte) []byte {
+	if bytes.Equal(a, b) {
+		return nil
+	}
+	al, bl := splitLines(string(a)), splitLines(string(b))
+	edits := diffLines(al, bl)
+	var out bytes.Buffer
+	fmt.Fprintf(&out, "--- %s\n+++ %s\n", aName, bName)
+	for i := 0; i < len(edits); {
+		// skip to the next change
+		if edits[i].Op == editEqual {
+			i++
+			continue
+		}
+		start := i - diffContext
+		if start < 0 {
+			start = 0
+		}
+		// extend the hunk while changes are close to each other
+		end := i
+		for j := i; j < len(edits); j++ {
+			if edits[j].Op != editEqual {
+				end = j + 1
+			} else if j-end >= 2*diffContext {
+				break
+			}
+		}
+		end += diffContext
+		if end > len(edits) {
+			end = len(edits)
+		}
+		writeHunk(&out, edits[start:end], al, bl)
+		i = end
+	}
+	return out.Bytes()
+}
+
+func writeHunk(out *bytes.Buffer, edits []edit, a, b []string) {
+	aStart, bStart := edits[0].A, edits[0].B
+	aCount, bCount := 0, 0
+	for _, e := range edits {
+		if e.Op != editInsert {
+			aCount++
+		}
+		if e.Op != editDelete {
+			bCount++
+		}
+	}
+	fmt.Fprintf(out, "@@ -%s +%s @@\n", hunkRange(aStart, aCount), hunkRange(bStart, bCount))
+	for _, e := range edits {
+		line := ""
+		switch e.Op {
+		case editDelete, editEqual:
+			line = a[e.A]
+		case editInsert:
+			line = b[e.B]
+		}
+		out.WriteByte(byte(e.Op))
+		out.WriteString(line)
+		if !strings.HasSuffix(line, "\n") {
+			out.WriteString("\n\\ No newline at end of file\n")
+		}
+	}
+}
+
+func hunkRange(start, count int) string {
+	if count == 0 {
+		// empty range refers to the line before
+		return fmt.Sprintf("%d,0", start)
+	}
+	if count == 1 {
+		return fmt.Sprintf("%d", start+1)
+	}
+	return fmt.Sprintf("%d,%d", start+1, count)
+}
+
+// splitLines splits text into lines, keeping line terminators.
+func splitLines(s string) []string {
+	var lines []string
+	for s != "" {
+		i := strings.IndexByte(s, '\n')
+		if i < 0 {
+			lines = append(lines, s)
+			break
+		}
+		lines = append(lines, s[:i+1])
+		s = s[i+1:]
+	}
+	return lines
+}
+
+// diffLines returns the shortest edit script from a to b using Myers' algorithm.
+func diffLines(a, b []string) []edit {
+	n, m := len(a), len(b)
+	max := n + m
+	off := max + 1
+	v := make([]int, 2*max+3)
+	var trace [][]int
+search:
+	for d := 0; d <= max; d++ {
+		trace = append(trace, append([]int(nil), v...))
+		for k := -d; k <= d; k += 2 {
+			var x int
+			if k == -d || k != d && v[off+k-1] < v[off+k+1] {
+				x = v[off+k+1]
+			} else {
+				x = v[off+k-1] + 1
+			}
+			y := x - k
+			for x < n && y < m && a[x] == b[y] {
+				x++
+				y++
+			}
+			v[off+k] = x
+			if x >= n && y >= m {
+				break search
+			}
+		}
+	}
+	// backtrack from the end
+	var edits []edit
+	x, y := n, m
+	for d := len(trace) - 1; d > 0; d-- {
+		v := trace[d]
+		k := x - y
+		var prevK int
+		if k == -d || k != d && v[off+k-1] < v[off+k+1] {
+			prevK = k + 1
+		} else {
+			prevK = k - 1
+		}
+		prevX := v[off+prevK]
+		prevY := prevX - prevK
+		for x > prevX && y > prevY {
+			x--
+			y--
+			edits = append(edits, edit{Op: editEqual, A: x, B: y})
+		}
+		if x == prevX {
+			edits = append(edits, edit{Op: editInsert, A: x, B: prevY})
+		} else {
+			edits = append(edits, edit{Op: editDelete, A: prevX, B: y})
+		}
+		x, y = prevX, prevY
+	}
+	for x > 0 && y > 0 {
+		x--
+		y--
+		edits = append(edits, edit{Op: editEqual, A: x, B: y})
+	}
+	// reverse
+	for i, j := 0, len(edits)-1; i < j; i, j = i+1, j-1 {
+		edits[i], edits[j] = edits[j], edits[i]
+	}
+	return edits
+}
@@ -0,0 +1,133 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+// numberedLines returns lines with numbers from 1 to n, with some lines replaced.
+func numberedLines(n int, replace map[int]string) string {
+	var b strings.Builder
+	for i := 1; i <= n; i++ {
+		if s, ok := replace[i]; ok {
+			b.WriteString(s)
+			continue
+		}
+		fmt.Fprintf(&b, "%d\n", i)
+	}
+	return b.String()
+}
+
+func TestUnifiedDiff(t *testing.T) {
+	cases := []struct {
+		Name string
+		A, B string
+		Diff string
+	}{
+		{Name: "equal", A: "a\nb\n", B: "a\nb\n"},
+		{Name: "empty", A: "", B: ""},
+		{
+			Name: "empty old",
+			A: "",
+			B: "a\n",
+			Diff: "@@ -0,0 +1 @@\n+a\n",
+		},
+		{
+			Name: "empty new",
+			A: "a\nb\n",
+			B: "",
+			Diff: "@@ -1,2 +0,0 @@\n-a\n-b\n",
+		},
+		{
+			Name: "no newline at end of old",
+			A: "a\nb",
+			B: "a\nc\n",
+			Diff: "@@ -1,2 +1,2 @@\n a\n-b\n\\ No newline at end of file\n+c\n",
+		},
+		{
+			Name: "no newline at end of new",
+			A: "a\n",
+			B: "a",
+			Diff: "@@ -1 +1 @@\n-a\n+a\n\\ No newline at end of file\n",
+		},
+		{
+			Name: "context",
+			A: numberedLines(10, nil),
+			B: numberedLines(10, map[int]string{5: "x\n"}),
+			Diff: "@@ -2,7 +2,7 @@\n 2\n 3\n 4\n-5\n+x\n 6\n 7\n 8\n",
+		},
+		{
+			Name: "insertion",
+			A: numberedLines(3, nil),
+			B: numberedLines(3, map[int]string{2: "2\nx\n"}),
+			Diff: "@@ -1,3 +1,4 @@\n 1\n 2\n+x\n 3\n",
+		},
+		{
+			Name: "merged hunks",
+			A: numberedLines(20, nil),
+			B: numberedLines(20, map[int]string{2: "x\n", 9: "y\n"}),
+			Diff: "@@ -1,12 +1,12 @@\n 1\n-2\n+x\n 3\n 4\n 5\n 6\n 7\n 8\n-9\n+y\n 10\n 11\n 12\n",
+		},
+		{
+			Name: "separate hunks",
+			A: numberedLines(20, nil),
+			B: numberedLines(20, map[int]string{2: "x\n", 10: "y\n"}),
+			Diff: "@@ -1,5 +1,5 @@\n 1\n-2\n+x\n 3\n 4\n 5\n" +
+				"@@ -7,7 +7,7 @@\n 7\n 8\n 9\n-10\n+y\n 11\n 12\n 13\n",
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.Name, func(t *testing.T) {
+			diff := unifiedDiff("a", "b", []byte(c.A), []byte(c.B))
+			if c.Diff == "" {
+				if diff != nil {
+					t.Fatalf("expected no diff, got\n%s", diff)
+				}
+				return
+			}
+			want := "--- a\n+++ b\n" + c.Diff
+			if string(diff) != want {
+				t.Fatalf("expected diff\n%s\ngot\n%s", want, diff)
+			}
+		})
+	}
+}
+
+func TestDiffLines(t *testing.T) {
+	cases := []struct {
+		A, B string
+		Ops string
+	}{
+		{"", "", ""},
+		{"abc", "abc", "   "},
+		{"abc", "", "---"},
+		{"", "abc", "+++"},
+		// example of the Myers paper, the shortest script has 5 edits
+		{"abcabba", "cbabac", "-- +  - +"},
+	}
+	for _, c := range cases {
+		a, b := strings.Split(c.A, ""), strings.Split(c.B, "")
+		edits := diffLines(a, b)
+		var ops strings.Builder
+		var x, y int
+		for _, e := range edits {
+			ops.WriteByte(byte(e.Op))
+			if e.A != x || e.B != y {
+				t.Errorf("%s %s: expected edit at %d %d, got %d %d", c.A, c.B, x, y, e.A, e.B)
+			}
+			if e.Op != editInsert {
+				x++
+			}
+			if e.Op != editDelete {
+				y++
+			}
+		}
+		if x != len(a) || y != len(b) {
+			t.Errorf("%s %s: edits cover %d and %d lines", c.A, c.B, x, y)
+		}
+		if ops.String() != c.Ops {
+			t.Errorf("%s %s: expected %q edits, got %q", c.A, c.B, c.Ops, ops.String())
+		}
+	}
+}
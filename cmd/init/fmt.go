@@ -0,0 +1,85 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/tie/x/config/format"
+	"github.com/tie/x/config/token"
+)
+
+// fmtCommand implements the fmt command.  It returns the exit code: 0 on success, 1 if some
+// files cannot be formatted and 2 on usage errors.
+func fmtCommand(args []string) int {
+	fset := flag.NewFlagSet("fmt", flag.ContinueOnError)
+	write := fset.Bool("w", false, "write result to source files instead of standard output")
+	diff := fset.Bool("d", false, "display diffs instead of rewriting files")
+	fset.Usage = func() {
+		fmt.Fprintln(fset.Output(), "usage: init fmt [-w] [-d] [files...]")
+		fset.PrintDefaults()
+	}
+	if err := fset.Parse(args); err != nil {
+		return 2
+	}
+	if fset.NArg() == 0 {
+		if *write {
+			fmt.Fprintln(os.Stderr, "cannot use -w with standard input")
+			return 2
+		}
+		src, err := io.ReadAll(os.Stdin)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 1
+		}
+		return formatFile("<stdin>", src, false, *diff)
+	}
+	status := 0
+	for _, name := range fset.Args() {
+		src, err := os.ReadFile(name)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			status = 1
+			continue
+		}
+		if s := formatFile(name, src, *write, *diff); s != 0 {
+			status = s
+		}
+	}
+	return status
+}
+
+// formatFile formats the source and writes the result according to flags.
+func formatFile(name string, src []byte, write, diff bool) int {
+	res, err := format.Source(src)
+	if err != nil {
+		if e, ok := err.(*token.Error); ok {
+			e.Pos.Filename = name
+		}
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	if diff {
+		os.Stdout.Write(unifiedDiff(name+".orig", name, src, res))
+	}
+	if write {
+		if bytes.Equal(src, res) {
+			return 0
+		}
+		info, err := os.Stat(name)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 1
+		}
+		if err := os.WriteFile(name, res, info.Mode().Perm()); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 1
+		}
+	}
+	if !write && !diff {
+		os.Stdout.Write(res)
+	}
+	return 0
+}
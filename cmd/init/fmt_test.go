@@ -0,0 +1,102 @@
+package main
+
+import (
+	"io"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestFmt(t *testing.T) {
+	const (
+		unformatted = "on boot\nstart  a\n"
+		formatted = "on boot\n    start a\n"
+		invalid = "on boot\n    start \"a\n"
+		diff = "--- a.rc.orig\n+++ a.rc\n@@ -1,2 +1,2 @@\n on boot\n-start  a\n+    start a\n"
+	)
+	cases := []struct {
+		Args []string
+		Status int
+		Stdout, Stderr string
+		// A is the expected content of a.rc after the command.
+		A string
+	}{
+		{Args: []string{"a.rc"}, Stdout: formatted, A: unformatted},
+		{Args: []string{"a.rc", "ok.rc"}, Stdout: formatted + formatted, A: unformatted},
+		{Args: []string{"-d", "a.rc"}, Stdout: diff, A: unformatted},
+		{Args: []string{"-d", "ok.rc"}, A: unformatted},
+		{Args: []string{"-w", "a.rc", "ok.rc"}, A: formatted},
+		{Args: []string{"-w", "-d", "a.rc"}, Stdout: diff, A: formatted},
+		{Args: []string{"bad.rc", "ok.rc"}, Status: 1, Stdout: formatted, Stderr: "bad.rc:2:11: unterminated quoted string\n", A: unformatted},
+		{Args: []string{"-w", "bad.rc"}, Status: 1, Stderr: "bad.rc:2:11: unterminated quoted string\n", A: unformatted},
+		{Args: []string{"missing.rc"}, Status: 1, Stderr: "open missing.rc: no such file or directory\n", A: unformatted},
+		{Args: []string{"-w"}, Status: 2, Stderr: "cannot use -w with standard input\n", A: unformatted},
+	}
+	for _, c := range cases {
+		t.Run(strings.Join(c.Args, " "), func(t *testing.T) {
+			t.Chdir(t.TempDir())
+			files := map[string]string{"a.rc": unformatted, "ok.rc": formatted, "bad.rc": invalid}
+			for name, data := range files {
+				if err := os.WriteFile(name, []byte(data), 0640); err != nil {
+					t.Fatal(err)
+				}
+			}
+			// already formatted files are not rewritten
+			mtime := time.Now().Add(-time.Hour).Truncate(time.Second)
+			if err := os.Chtimes("ok.rc", mtime, mtime); err != nil {
+				t.Fatal(err)
+			}
+			var status int
+			var stdout string
+			stderr := captureStderr(t, func() {
+				stdout = capture(t, &os.Stdout, func() {
+					status = fmtCommand(c.Args)
+				})
+			})
+			if status != c.Status || stdout != c.Stdout || stderr != c.Stderr {
+				t.Fatalf("expected status %d, output\n%s\nand errors\n%s\ngot status %d, output\n%s\nand errors\n%s",
+					c.Status, c.Stdout, c.Stderr, status, stdout, stderr)
+			}
+			if data, err := os.ReadFile("a.rc"); err != nil || string(data) != c.A {
+				t.Fatalf("expected %q in a.rc, got %q, %v", c.A, data, err)
+			}
+			if info, err := os.Stat("a.rc"); err != nil || info.Mode().Perm() != 0640 {
+				t.Fatalf("expected a.rc mode to be kept, got %v, %v", info.Mode(), err)
+			}
+			if info, err := os.Stat("ok.rc"); err != nil || !info.ModTime().Equal(mtime) {
+				t.Fatalf("expected ok.rc to be untouched, got %v, %v", info.ModTime(), err)
+			}
+		})
+	}
+}
+
+func TestFmtStdin(t *testing.T) {
+	stdin, err := os.CreateTemp(t.TempDir(), "stdin")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer stdin.Close()
+	if _, err := stdin.WriteString("on boot\nstart a\n"); err != nil {
+		t.Fatal(err)
+	}
+	saved := os.Stdin
+	defer func() { os.Stdin = saved }()
+	for _, c := range []struct {
+		Args []string
+		Stdout string
+	}{
+		{nil, "on boot\n    start a\n"},
+		{[]string{"-d"}, "--- <stdin>.orig\n+++ <stdin>\n@@ -1,2 +1,2 @@\n on boot\n-start a\n+    start a\n"},
+	} {
+		stdin.Seek(0, io.SeekStart)
+		os.Stdin = stdin
+		var status int
+		out := capture(t, &os.Stdout, func() {
+			status = fmtCommand(c.Args)
+		})
+		if status != 0 || out != c.Stdout {
+			t.Errorf("%q: expected status 0 and output\n%s\ngot status %d and output\n%s", c.Args, c.Stdout, status, out)
+		}
+	}
+}
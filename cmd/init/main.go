@@ -2,18 +2,72 @@ package main
 
 import (
 	"bufio"
+	"fmt"
 	"log"
 	"os"
 	"unicode/utf8"
 
 	"github.com/tie/x/config"
+	"github.com/tie/x/config/parser"
+	"github.com/tie/x/config/token"
 )
 
+const usage = `usage:
+	init                          parse config from standard input
+	init check [flags] files      check config files and their imports
+	init fmt [-w] [-d] [files]    format config files
+`
+
 func main() {
+	if len(os.Args) > 1 {
+		switch os.Args[1] {
+		case "check":
+			os.Exit(check(os.Args[2:]))
+		case "fmt":
+			os.Exit(fmtCommand(os.Args[2:]))
+		case "help", "-h", "-help", "--help":
+			fmt.Fprint(os.Stderr, usage)
+			os.Exit(2)
+		}
+	}
 	r := bufio.NewReaderSize(os.Stdin, utf8.UTFMax)
-	unit, err := config.Parse(r)
+	f, err := config.Parse("<stdin>", r, parser.AllErrors)
 	if err != nil {
-		log.Fatal(err)
+		printErrors(err, nil)
+		os.Exit(1)
+	}
+	for _, imp := range f.Imports {
+		log.Printf("import %q", imp.Path)
+	}
+	for _, trig := range f.Triggers {
+		log.Printf("on %q: %d commands", trig.Conditions, len(trig.Commands))
+	}
+	for _, svc := range f.Services {
+		log.Printf("service %s %q %q: %d options", svc.Name, svc.Path, svc.Args, len(svc.Options))
+	}
+}
+
+// printErrors prints errors in file:line:column: message format, one per line.
+// Filenames are converted with the name function if it is not nil.
+func printErrors(err error, name func(string) string) {
+	errs, ok := err.(token.ErrorList)
+	if !ok {
+		e, ok := err.(*token.Error)
+		if !ok {
+			fmt.Fprintln(os.Stderr, err)
+			return
+		}
+		errs = token.ErrorList{e}
+	}
+	for _, e := range errs {
+		if name != nil {
+			e.Pos.Filename = name(e.Pos.Filename)
+			if e.Related != nil {
+				related := *e.Related
+				related.Pos.Filename = name(related.Pos.Filename)
+				e.Related = &related
+			}
+		}
+		fmt.Fprintln(os.Stderr, e)
 	}
-	log.Println(unit)
 }
@@ -0,0 +1,358 @@
+// Package edit implements programmatic editing of config files.
+//
+// Edits operate on the lossless syntax tree, so that bytes of untouched statements, including
+// comments and spaces, are preserved.  New statements are indented like their neighbours.
+// Token positions are not updated by edits, parse the result of Bytes to get them.
+package edit
+
+import (
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/tie/x/config"
+	"github.com/tie/x/config/parser"
+	"github.com/tie/x/config/token"
+)
+
+// defaultIndent is the indentation of new statements in sections without statements.
+const defaultIndent = "    "
+
+// File is an editable config file.
+type File struct {
+	tree *parser.Tree
+	syn parser.Syntax
+	// newline is the line separator of new statements, the first separator of the source
+	newline string
+}
+
+// Parse parses config file source for editing.  Source must be lexically correct.  The file
+// keeps a copy of the source, so the caller may reuse src afterwards.
+func Parse(filename string, src []byte) (*File, error) {
+	return ParseSyntax(filename, src, config.Syntax)
+}
+
+// ParseSyntax parses source of a file with the given syntax for editing.  Only the default
+// lexer dialect is supported, since new statements are quoted with token.Quote.  As with
+// Parse, src is copied.
+func ParseSyntax(filename string, src []byte, syn parser.Syntax) (*File, error) {
+	if syn.Lexer.Dialect() != nil {
+		return nil, fmt.Errorf("edit: lexer dialect is not supported")
+	}
+	tree, err := parser.ParseTree(filename, src, syn, 0)
+	if err != nil {
+		return nil, err
+	}
+	return &File{
+		tree: tree,
+		syn: syn,
+		newline: newline(tree),
+	}, nil
+}
+
+// Bytes returns the source of the edited file.
+func (f *File) Bytes() []byte {
+	return f.tree.Bytes()
+}
+
+// Sections returns all sections of the file in order.
+func (f *File) Sections() []*Section {
+	var sections []*Section
+	for _, nodes := range f.tree.Sections[1:] {
+		sections = append(sections, &Section{file: f, header: nodes[0]})
+	}
+	return sections
+}
+
+// Section returns the first section with the given header arguments, e.g. "on", "boot".
+// It returns nil if there is no such section.
+func (f *File) Section(header ...string) *Section {
+	for _, s := range f.Sections() {
+		args, err := s.Header().Text()
+		if err == nil && equal(args, header) {
+			return s
+		}
+	}
+	return nil
+}
+
+// Service returns the section of the named service, or nil if there is no such service.
+func (f *File) Service(name string) *Section {
+	for _, s := range f.Sections() {
+		args, err := s.Header().Text()
+		if err == nil && len(args) > 1 && args[0] == "service" && args[1] == name {
+			return s
+		}
+	}
+	return nil
+}
+
+// AddSection appends a new section with the given header arguments.  The section is
+// separated from the preceding statements with a blank line.
+func (f *File) AddSection(header ...string) (*Section, error) {
+	if len(header) == 0 {
+		return nil, fmt.Errorf("empty section header")
+	}
+	if _, ok := f.syn.Sections[header[0]]; !ok {
+		return nil, fmt.Errorf("unknown section %q", header[0])
+	}
+	n, err := f.newNode("", header)
+	if err != nil {
+		return nil, err
+	}
+	// keep comments at the end of file before the new section
+	leading := f.tree.Trailing
+	f.tree.Trailing = nil
+	if len(leading) > 0 && leading[len(leading)-1].Typ != token.SepToken {
+		leading = append(leading, f.sep())
+	}
+	if len(f.nodes()) > 0 || len(leading) > 0 {
+		leading = append(leading, f.sep())
+	}
+	n.Leading = leading
+	f.tree.Sections = append(f.tree.Sections, []*parser.Node{n})
+	f.terminate()
+	return &Section{file: f, header: n}, nil
+}
+
+// RemoveSection removes the section along with the comments preceding its statements.
+func (f *File) RemoveSection(s *Section) {
+	i := s.index()
+	if i < 0 {
+		return
+	}
+	f.tree.Sections = append(f.tree.Sections[:i], f.tree.Sections[i+1:]...)
+	f.terminate()
+}
+
+// nodes returns all nodes of the file in order.
+func (f *File) nodes() []*parser.Node {
+	var nodes []*parser.Node
+	for _, section := range f.tree.Sections {
+		nodes = append(nodes, section...)
+	}
+	return nodes
+}
+
+// terminate makes sure that every statement but the last one ends with a separator.
+func (f *File) terminate() {
+	nodes := f.nodes()
+	for i, n := range nodes {
+		if i == len(nodes)-1 && len(f.tree.Trailing) == 0 {
+			break
+		}
+		if len(n.Trailing) == 0 || n.Trailing[len(n.Trailing)-1].Typ != token.SepToken {
+			n.Trailing = append(n.Trailing, f.sep())
+		}
+	}
+}
+
+// Section is a section of an editable file.  Sections remain valid until they are removed.
+type Section struct {
+	file *File
+	header *parser.Node
+}
+
+// Header returns the section header statement.
+func (s *Section) Header() parser.Statement {
+	return s.header.Statement()
+}
+
+// Len returns the number of statements in the section body.
+func (s *Section) Len() int {
+	return len(s.body())
+}
+
+// Statement returns the i-th statement of the section body.
+func (s *Section) Statement(i int) (parser.Statement, error) {
+	body := s.body()
+	if i < 0 || i >= len(body) {
+		return nil, indexError(i, len(body))
+	}
+	return body[i].Statement(), nil
+}
+
+// Find returns index of the first body statement with the given directive, or -1.
+func (s *Section) Find(directive string) int {
+	for i, n := range s.body() {
+		args, err := n.Statement().Text()
+		if err == nil && len(args) > 0 && args[0] == directive {
+			return i
+		}
+	}
+	return -1
+}
+
+// Insert inserts a new statement with the given arguments before the i-th body statement.
+// Index equal to Len appends the statement.
+func (s *Section) Insert(i int, args ...string) error {
+	k := s.index()
+	if k < 0 {
+		return fmt.Errorf("section is removed")
+	}
+	body := s.body()
+	if i < 0 || i > len(body) {
+		return fmt.Errorf("statement index %d out of range [0, %d]", i, len(body))
+	}
+	n, err := s.file.newNode(s.indent(i), args)
+	if err != nil {
+		return err
+	}
+	nodes := s.file.tree.Sections[k]
+	nodes = append(nodes, nil)
+	copy(nodes[i+2:], nodes[i+1:])
+	nodes[i+1] = n
+	s.file.tree.Sections[k] = nodes
+	s.file.terminate()
+	return nil
+}
+
+// Append appends a new statement with the given arguments to the section body.
+func (s *Section) Append(args ...string) error {
+	return s.Insert(s.Len(), args...)
+}
+
+// Remove removes the i-th body statement along with the comments preceding it.
+func (s *Section) Remove(i int) error {
+	if n := s.Len(); i < 0 || i >= n {
+		return indexError(i, n)
+	}
+	k := s.index()
+	nodes := s.file.tree.Sections[k]
+	s.file.tree.Sections[k] = append(nodes[:i+1], nodes[i+2:]...)
+	s.file.terminate()
+	return nil
+}
+
+// Replace replaces arguments of the i-th body statement.  Indentation and comments are preserved.
+func (s *Section) Replace(i int, args ...string) error {
+	body := s.body()
+	if i < 0 || i >= len(body) {
+		return indexError(i, len(body))
+	}
+	old := body[i]
+	n, err := s.file.newNode(indentOf(old), args)
+	if err != nil {
+		return err
+	}
+	old.Tokens = n.Tokens
+	return nil
+}
+
+// Set replaces the first body statement with the given directive, or appends a new one.
+func (s *Section) Set(directive string, args ...string) error {
+	args = append([]string{directive}, args...)
+	if i := s.Find(directive); i >= 0 {
+		return s.Replace(i, args...)
+	}
+	return s.Append(args...)
+}
+
+// Unset removes all body statements with the given directive.  It reports whether any
+// statements were removed.
+func (s *Section) Unset(directive string) bool {
+	removed := false
+	for i := s.Find(directive); i >= 0; i = s.Find(directive) {
+		s.Remove(i)
+		removed = true
+	}
+	return removed
+}
+
+// index returns index of the section in the tree, or -1 if the section was removed.
+func (s *Section) index() int {
+	for i, nodes := range s.file.tree.Sections {
+		if i > 0 && nodes[0] == s.header {
+			return i
+		}
+	}
+	return -1
+}
+
+func (s *Section) body() []*parser.Node {
+	i := s.index()
+	if i < 0 {
+		return nil
+	}
+	return s.file.tree.Sections[i][1:]
+}
+
+// indent returns indentation for a new statement inserted before the i-th body statement.
+func (s *Section) indent(i int) string {
+	body := s.body()
+	switch {
+	case i < len(body):
+		return indentOf(body[i])
+	case len(body) > 0:
+		return indentOf(body[len(body)-1])
+	}
+	return defaultIndent
+}
+
+// indentOf returns indentation of the node statement.
+func indentOf(n *parser.Node) string {
+	if len(n.Tokens) > 0 && n.Tokens[0].Typ == token.SpaceToken {
+		return n.Tokens[0].Val
+	}
+	return ""
+}
+
+// newNode returns a node of a new statement with the given indentation and arguments.
+func (f *File) newNode(indent string, args []string) (*parser.Node, error) {
+	if len(args) == 0 {
+		return nil, fmt.Errorf("empty statement")
+	}
+	quoted := make([]string, len(args))
+	for i, arg := range args {
+		quoted[i] = token.Quote(arg)
+	}
+	src := indent + strings.Join(quoted, " ") + f.newline
+	p := parser.NewParser("", strings.NewReader(src))
+	n, err := p.NextNode()
+	if err != nil && err != io.EOF {
+		return nil, err
+	}
+	return n, nil
+}
+
+func (f *File) sep() token.Token {
+	return token.Token{Typ: token.SepToken, Val: f.newline}
+}
+
+// newline returns the first line separator of the tree, or "\n" if there is none.
+func newline(t *parser.Tree) string {
+	for _, section := range t.Sections {
+		for _, n := range section {
+			for _, toks := range [][]token.Token{n.Leading, n.Tokens, n.Trailing} {
+				for _, tok := range toks {
+					if tok.Typ == token.SepToken {
+						return tok.Val
+					}
+				}
+			}
+		}
+	}
+	for _, tok := range t.Trailing {
+		if tok.Typ == token.SepToken {
+			return tok.Val
+		}
+	}
+	return "\n"
+}
+
+// indexError returns the error of a body statement index out of range.
+func indexError(i, n int) error {
+	return fmt.Errorf("statement index %d out of range [0, %d)", i, n)
+}
+
+func equal(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
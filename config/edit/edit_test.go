@@ -0,0 +1,204 @@
+package edit
+
+import (
+	"strings"
+	"testing"
+)
+
+const source = `# services
+service a /bin/a # first
+	class core
+	# keep it off
+	disabled
+
+service b /bin/b
+  user root  # trailing
+
+on boot
+    start a
+# eof`
+
+func TestEdit(t *testing.T) {
+	cases := []struct {
+		Name string
+		Edit func(f *File) error
+		Output string
+	}{
+		{
+			Name: "Unchanged",
+			Edit: func(f *File) error {
+				return nil
+			},
+			Output: source,
+		},
+		{
+			Name: "AppendOption",
+			Edit: func(f *File) error {
+				return f.Service("a").Append("setenv", "PATH", "/bin:/sbin")
+			},
+			Output: strings.Replace(source, "\tdisabled\n", "\tdisabled\n\tsetenv PATH /bin:/sbin\n", 1),
+		},
+		{
+			Name: "InsertOption",
+			Edit: func(f *File) error {
+				return f.Service("b").Insert(0, "setenv", "A", "x y")
+			},
+			Output: strings.Replace(source, "  user root", "  setenv A \"x y\"\n  user root", 1),
+		},
+		{
+			Name: "RemoveOption",
+			Edit: func(f *File) error {
+				s := f.Service("a")
+				if !s.Unset("disabled") {
+					t.Errorf("expected disabled option")
+				}
+				return nil
+			},
+			Output: strings.Replace(source, "\t# keep it off\n\tdisabled\n", "", 1),
+		},
+		{
+			Name: "ReplaceOption",
+			Edit: func(f *File) error {
+				return f.Service("b").Set("user", "system")
+			},
+			Output: strings.Replace(source, "user root", "user system", 1),
+		},
+		{
+			Name: "SetMissingOption",
+			Edit: func(f *File) error {
+				return f.Service("b").Set("disabled")
+			},
+			Output: strings.Replace(source, "# trailing\n", "# trailing\n  disabled\n", 1),
+		},
+		{
+			Name: "AppendAtEOF",
+			Edit: func(f *File) error {
+				return f.Section("on", "boot").Append("start", "b")
+			},
+			Output: strings.Replace(source, "    start a\n", "    start a\n    start b\n", 1),
+		},
+		{
+			Name: "AddSection",
+			Edit: func(f *File) error {
+				s, err := f.AddSection("service", "c", "/bin/c")
+				if err != nil {
+					return err
+				}
+				return s.Append("oneshot")
+			},
+			Output: source + "\n\nservice c /bin/c\n    oneshot\n",
+		},
+		{
+			Name: "RemoveSection",
+			Edit: func(f *File) error {
+				f.RemoveSection(f.Service("b"))
+				return nil
+			},
+			Output: strings.Replace(source, "service b /bin/b\n  user root  # trailing\n\n", "", 1),
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.Name, func(t *testing.T) {
+			f, err := Parse("", []byte(source))
+			if err != nil {
+				t.Fatalf("unexpected %s error", err)
+			}
+			if err := c.Edit(f); err != nil {
+				t.Fatalf("unexpected %s error", err)
+			}
+			if out := string(f.Bytes()); out != c.Output {
+				t.Errorf("expected\n%s\ngot\n%s", c.Output, out)
+			}
+		})
+	}
+}
+
+func TestEditNoNewline(t *testing.T) {
+	f, err := Parse("", []byte("service a /bin/a"))
+	if err != nil {
+		t.Fatalf("unexpected %s error", err)
+	}
+	if err := f.Service("a").Append("disabled"); err != nil {
+		t.Fatalf("unexpected %s error", err)
+	}
+	expected := "service a /bin/a\n    disabled\n"
+	if out := string(f.Bytes()); out != expected {
+		t.Errorf("expected %q, got %q", expected, out)
+	}
+}
+
+func TestEditSourceReuse(t *testing.T) {
+	src := []byte("service a /bin/a\n")
+	f, err := Parse("", src)
+	if err != nil {
+		t.Fatalf("unexpected %s error", err)
+	}
+	copy(src, "on boot\n")
+	if err := f.Service("a").Append("disabled"); err != nil {
+		t.Fatalf("unexpected %s error", err)
+	}
+	expected := "service a /bin/a\n    disabled\n"
+	if out := string(f.Bytes()); out != expected {
+		t.Errorf("expected %q, got %q", expected, out)
+	}
+}
+
+func TestEditCRLF(t *testing.T) {
+	f, err := Parse("", []byte("# services\r\nservice a /bin/a\r\n    user root\r\n# eof"))
+	if err != nil {
+		t.Fatalf("unexpected %s error", err)
+	}
+	s := f.Service("a")
+	if err := s.Append("disabled"); err != nil {
+		t.Fatalf("unexpected %s error", err)
+	}
+	if _, err := f.AddSection("on", "boot"); err != nil {
+		t.Fatalf("unexpected %s error", err)
+	}
+	if err := f.Section("on", "boot").Append("start", "a"); err != nil {
+		t.Fatalf("unexpected %s error", err)
+	}
+	if stmt, err := s.Statement(1); err != nil || stmt.Directive() != "disabled" {
+		t.Fatalf("unexpected %v statement, %v", stmt, err)
+	}
+	expected := "# services\r\nservice a /bin/a\r\n    user root\r\n    disabled\r\n# eof\r\n\r\non boot\r\n    start a\r\n"
+	if out := string(f.Bytes()); out != expected {
+		t.Errorf("expected %q, got %q", expected, out)
+	}
+}
+
+func TestEditErrors(t *testing.T) {
+	f, err := Parse("", []byte("service a /bin/a\n"))
+	if err != nil {
+		t.Fatalf("unexpected %s error", err)
+	}
+	if f.Service("b") != nil {
+		t.Errorf("unexpected service b")
+	}
+	if _, err := f.AddSection("unknown"); err == nil {
+		t.Errorf("expected error for unknown section")
+	}
+	if err := f.Service("a").Insert(2, "disabled"); err == nil {
+		t.Errorf("expected error for index out of range")
+	}
+	if err := f.Service("a").Append(); err == nil {
+		t.Errorf("expected error for empty statement")
+	}
+	if _, err := f.Service("a").Statement(0); err == nil {
+		t.Errorf("expected error for index out of range")
+	}
+	if err := f.Service("a").Remove(-1); err == nil {
+		t.Errorf("expected error for index out of range")
+	}
+	if err := f.Service("a").Replace(0, "disabled"); err == nil {
+		t.Errorf("expected error for index out of range")
+	}
+	s := f.Service("a")
+	f.RemoveSection(s)
+	if err := s.Append("disabled"); err == nil {
+		t.Errorf("expected error for removed section")
+	}
+	if _, err := Parse("", []byte("service \"a\n")); err == nil {
+		t.Errorf("expected syntax error")
+	}
+}
@@ -0,0 +1,67 @@
+package config
+
+import (
+	"github.com/tie/x/config/token"
+)
+
+// File is a parsed config file.
+type File struct {
+	Name string
+	Services []*Service
+	Triggers []*Trigger
+	Imports []*Import
+}
+
+// Service is a program that init launches and (optionally) restarts.
+//
+//	service <name> <pathname> [ <argument> ]*
+//	    <option>
+//	    ...
+type Service struct {
+	Pos token.Position
+	Name string
+	Path string
+	Args []string
+	Options []Command
+	// PathPos and ArgPos are positions of Path and Args in the source.  They are zero for
+	// services that are not parsed, and Pos is used instead.
+	PathPos token.Position
+	ArgPos []token.Position
+}
+
+// Trigger is a sequence of commands executed when the trigger conditions are met.
+//
+//	on <trigger> [&& <trigger>]*
+//	    <command>
+//	    ...
+type Trigger struct {
+	Pos token.Position
+	Conditions []Condition
+	Commands []Command
+}
+
+// Import includes another config file.
+//
+//	import <path>
+type Import struct {
+	Pos token.Position
+	Path string
+}
+
+// Command is a statement in a section body, i.e. a service option or a trigger command.
+type Command struct {
+	Pos token.Position
+	Name string
+	Args []string
+	// ArgPos are positions of Args in the source.  It is nil for commands that are not
+	// parsed, and Pos is used instead.
+	ArgPos []token.Position
+}
+
+// argPos returns the position of the i-th argument.
+func (c Command) argPos(i int) token.Position {
+	if i < len(c.ArgPos) {
+		return c.ArgPos[i]
+	}
+	return c.Pos
+}
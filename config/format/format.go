@@ -0,0 +1,198 @@
+// Package format implements canonical formatting of init config files.
+//
+// The formatter works on lexer tokens, so comments are preserved and statements need not be
+// valid, only lexically correct.  Formatting rules are:
+//
+//   - section headers and statements outside of sections start at the first column;
+//   - statements in section bodies are indented with four spaces;
+//   - continuation lines are indented four more spaces than the statement;
+//   - comment lines are indented as the statement that follows them;
+//   - tokens are separated with a single space, trailing spaces are removed;
+//   - text tokens are quoted canonically, see token.Quote;
+//   - runs of blank lines are collapsed, leading and trailing blank lines are removed;
+//   - lines end with LF, byte order mark is removed.
+package format
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/tie/x/config"
+	"github.com/tie/x/config/lexer"
+	"github.com/tie/x/config/parser"
+	"github.com/tie/x/config/token"
+)
+
+// Indent is the indentation of section bodies and continuation lines.
+const Indent = "    "
+
+// Source formats config file source using config.Syntax to find section headers.
+func Source(src []byte) ([]byte, error) {
+	return SourceSyntax(src, config.Syntax)
+}
+
+// SourceSyntax formats config file source.  Section headers are statements whose directive
+// is a key of syn.Sections.  Syntax check functions are not used.  Only the default lexer
+// dialect is supported, since text tokens are quoted with token.Quote.
+func SourceSyntax(src []byte, syn parser.Syntax) ([]byte, error) {
+	if syn.Lexer.Dialect() != nil {
+		return nil, fmt.Errorf("format: lexer dialect is not supported")
+	}
+	lines, err := split(src)
+	if err != nil {
+		return nil, err
+	}
+	indents := indentLines(lines, syn)
+	var b bytes.Buffer
+	blank := false
+	for i, ln := range lines {
+		if ln.blank() {
+			blank = b.Len() > 0
+			continue
+		}
+		if blank {
+			b.WriteByte('\n')
+			blank = false
+		}
+		if err := ln.format(&b, indents[i]); err != nil {
+			return nil, err
+		}
+		b.WriteByte('\n')
+	}
+	return b.Bytes(), nil
+}
+
+// line is a sequence of tokens up to a separator.  Line may span several physical lines
+// if it is folded.
+type line []token.Token
+
+// split splits source into lines.
+func split(src []byte) ([]line, error) {
+	l := lexer.NewLexer("", bytes.NewReader(src))
+	var lines []line
+	var ln line
+	for {
+		tok, err := l.NextToken()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		if tok.Typ == token.SepToken {
+			lines = append(lines, ln)
+			ln = nil
+			continue
+		}
+		ln = append(ln, tok)
+	}
+	if len(ln) > 0 {
+		lines = append(lines, ln)
+	}
+	return lines, nil
+}
+
+func (ln line) blank() bool {
+	for _, tok := range ln {
+		if tok.Typ != token.SpaceToken {
+			return false
+		}
+	}
+	return true
+}
+
+// directive returns the first text token of the line, or nil if the line has no text.
+func (ln line) directive() *token.Token {
+	for i := range ln {
+		if ln[i].Typ == token.TextToken {
+			return &ln[i]
+		}
+	}
+	return nil
+}
+
+// indentLines returns indentation of each line.  Comment lines are indented as the
+// following statement.
+func indentLines(lines []line, syn parser.Syntax) []string {
+	indents := make([]string, len(lines))
+	section := false
+	for i, ln := range lines {
+		dir := ln.directive()
+		if dir == nil {
+			continue
+		}
+		if _, ok := syn.Sections[dir.Val]; ok {
+			section = true
+			continue
+		}
+		if section {
+			indents[i] = Indent
+		}
+	}
+	next := ""
+	for i := len(lines) - 1; i >= 0; i-- {
+		if lines[i].directive() != nil {
+			next = indents[i]
+			continue
+		}
+		indents[i] = next
+	}
+	return indents
+}
+
+// format writes the formatted line without separator.
+func (ln line) format(b *bytes.Buffer, indent string) error {
+	b.WriteString(indent)
+	// start is set at the start of a physical line
+	start := true
+	for _, tok := range ln {
+		switch tok.Typ {
+		case token.TextToken:
+			if tok.Folding() {
+				// line folding
+				b.WriteString(" \\\n")
+				b.WriteString(indent + Indent)
+				start = true
+				continue
+			}
+			if !start {
+				b.WriteByte(' ')
+			}
+			start = false
+			val, err := normalize(tok)
+			if err != nil {
+				return err
+			}
+			b.WriteString(val)
+			if strings.HasSuffix(val, "\\\n") {
+				b.WriteString(indent + Indent)
+				start = true
+			}
+		case token.CommentToken:
+			if !start {
+				b.WriteByte(' ')
+			}
+			b.WriteString(strings.TrimRightFunc(tok.Val, isSpace))
+		}
+	}
+	return nil
+}
+
+// normalize returns canonical form of a text token.  Tokens with folded lines are left as is,
+// except for line endings.
+func normalize(tok token.Token) (string, error) {
+	if strings.Contains(tok.Val, "\\\n") || strings.Contains(tok.Val, "\\\r\n") {
+		return strings.Replace(tok.Val, "\r\n", "\n", -1), nil
+	}
+	s, err := tok.Text()
+	if err != nil {
+		return "", err
+	}
+	return token.Quote(s), nil
+}
+
+func isSpace(r rune) bool {
+	return r == ' ' || r == '\t' || r == '\r' || r == '\v' || r == '\f'
+}
@@ -0,0 +1,95 @@
+package format
+
+import (
+	"testing"
+)
+
+func TestSource(t *testing.T) {
+	cases := []struct {
+		Name string
+		Input string
+		Output string
+	}{
+		{
+			Name: "Empty",
+			Input: "",
+			Output: "",
+		},
+		{
+			Name: "Indentation",
+			Input: "on boot\nstart a\n\t  stop b\nservice a /bin/a\n        user root\n",
+			Output: "on boot\n    start a\n    stop b\nservice a /bin/a\n    user root\n",
+		},
+		{
+			Name: "Spaces",
+			Input: "service   a\t/bin/a   x  \n    class \t core   \n",
+			Output: "service a /bin/a x\n    class core\n",
+		},
+		{
+			Name: "BlankLines",
+			Input: "\n\n\nimport a.rc\n\n\n\nimport b.rc\n\n\n",
+			Output: "import a.rc\n\nimport b.rc\n",
+		},
+		{
+			Name: "MissingNewline",
+			Input: "import a.rc",
+			Output: "import a.rc\n",
+		},
+		{
+			Name: "Comments",
+			Input: "# header  \n  on boot # trailing   \n# body\n  start a\n\n\t# next\nservice a /bin/a\n",
+			Output: "# header\non boot # trailing\n    # body\n    start a\n\n# next\nservice a /bin/a\n",
+		},
+		{
+			Name: "Quoting",
+			Input: "service a /bin/a \"x\" a\\ b 'c d' \"\" '' '\\q' x\\\\y'\\d' \"it's\"\n",
+			Output: "service a /bin/a x \"a b\" \"c d\" \"\" \"\" '\\q' 'x\\y\\d' \"it's\"\n",
+		},
+		{
+			Name: "Folding",
+			Input: "service a /bin/a \\\n  x \\\ny\n    user a\\\nb\n",
+			Output: "service a /bin/a \\\n    x \\\n    y\n    user a\\\n        b\n",
+		},
+		{
+			Name: "LineEndings",
+			Input: "\uFEFFon boot\r\n\r\n  start a \\\r\n b\r\n    stop \"a\\\r\nb\"\r\n",
+			Output: "on boot\n\n    start a \\\n        b\n    stop \"a\\\nb\"\n",
+		},
+		{
+			Name: "TopLevel",
+			Input: "  stray statement\non boot\n",
+			Output: "stray statement\non boot\n",
+		},
+	}
+	for _, c := range cases {
+		c := c
+		t.Run(c.Name, func(t *testing.T) {
+			out, err := Source([]byte(c.Input))
+			if err != nil {
+				t.Fatalf("unexpected %s error", err)
+			}
+			if string(out) != c.Output {
+				t.Fatalf("expected\n%q\ngot\n%q", c.Output, out)
+			}
+			// formatting is idempotent
+			again, err := Source(out)
+			if err != nil {
+				t.Fatalf("unexpected %s error on second pass", err)
+			}
+			if string(again) != string(out) {
+				t.Fatalf("formatting is not idempotent\n%q\n%q", out, again)
+			}
+		})
+	}
+}
+
+func TestSourceErrors(t *testing.T) {
+	for _, input := range []string{
+		"service a \"b\n",
+		"service a \\q\n",
+	} {
+		if _, err := Source([]byte(input)); err == nil {
+			t.Errorf("expected error for %q", input)
+		}
+	}
+}
@@ -2,6 +2,7 @@ package tokenh
 
 import (
 	"fmt"
+	"strings"
 
 	"github.com/tie/x/config/token"
 )
@@ -22,6 +23,14 @@ func Comment(val string, pos, end string) token.Token {
 	return tok(token.CommentToken, val, pos, end)
 }
 
+// Error returns a syntax error with the given code and position.  Message is left empty.
+func Error(code token.ErrorCode, at string) *token.Error {
+	return &token.Error{
+		Pos: pos(at),
+		Code: code,
+	}
+}
+
 func tok(typ token.TokenType, val string, a, b string) token.Token {
 	return token.Token{
 		Typ: typ,
@@ -31,7 +40,14 @@ func tok(typ token.TokenType, val string, a, b string) token.Token {
 	}
 }
 
+// pos parses position in [filename:]line:column(offset) format.
 func pos(s string) token.Position {
+	var filename string
+	if i := strings.LastIndexByte(s, ':'); i >= 0 {
+		if j := strings.LastIndexByte(s[:i], ':'); j >= 0 {
+			filename, s = s[:j], s[j+1:]
+		}
+	}
 	var off, line, col int
 	_, err := fmt.Sscanf(s, "%d:%d(%d)", &line, &col, &off)
 	if err != nil {
@@ -40,6 +56,7 @@ func pos(s string) token.Position {
 	line -= 1
 	col -= 1
 	return token.Position{
+		Filename: filename,
 		Line: line,
 		Column: col,
 		Offset: off,
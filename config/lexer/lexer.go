@@ -4,28 +4,96 @@ import (
 	"io"
 	"strings"
 	"unicode"
+	"unicode/utf8"
 
 	"github.com/tie/x/config/token"
 )
 
 type Lexer struct {
+	// reader is the input, or nil if the input is src
 	reader io.RuneReader
 	buffer strings.Builder
+	// src is the input of lexers created with NewLexerBytes, token values are sliced out of it
+	src string
+	// srcPos is the offset of the next unread byte in src
+	srcPos int
+	// invalid is set if the current token has invalid UTF-8 sequences
+	invalid bool
+	opts Options
+	// dialect is the dialect of text tokens, nil for the default one
+	dialect *token.Dialect
 	startPos, endPos token.Position
-	next struct {
-		r rune
-		size int
-		err error
-	}
+	// next is the peeked rune, and after is the rune following it if it was peeked too.
+	next, after lookahead
+	// err is the first syntax error in the current token.
+	err *token.Error
+	started bool
+	bom bool
+}
+
+// lookahead is a result of ReadRune that is not accepted yet.  It is empty if both size
+// and err are zero.
+type lookahead struct {
+	r rune
+	size int
+	err error
+}
+
+func (la lookahead) empty() bool {
+	return la.err == nil && la.size <= 0
+}
+
+// NewLexer returns a lexer reading from r with default options.  Filename is recorded in
+// token positions.
+func NewLexer(filename string, r io.RuneReader) *Lexer {
+	return NewLexerOptions(filename, r, Options{})
 }
 
-func NewLexer(r io.RuneReader) *Lexer {
-	return &Lexer{
+// NewLexerOptions returns a lexer reading from r configured with opts.
+func NewLexerOptions(filename string, r io.RuneReader, opts Options) *Lexer {
+	l := &Lexer{
 		reader: r,
+		opts: opts.withDefaults(),
+		dialect: opts.Dialect(),
 	}
+	l.startPos.Filename = filename
+	l.endPos.Filename = filename
+	return l
 }
 
+// NewLexerBytes returns a lexer reading from src with default options.  Src is copied once,
+// and token values are sliced out of the copy, so src may be modified afterwards.  Values of
+// tokens with invalid UTF-8 sequences are copied, since the sequences are replaced, and the
+// source of such tokens is kept in Raw.
+func NewLexerBytes(filename string, src []byte) *Lexer {
+	return NewLexerBytesOptions(filename, src, Options{})
+}
+
+// NewLexerBytesOptions returns a lexer reading from src configured with opts.
+func NewLexerBytesOptions(filename string, src []byte, opts Options) *Lexer {
+	l := NewLexerOptions(filename, nil, opts)
+	l.src = string(src)
+	return l
+}
+
+// NextToken returns the next token.  Syntax errors are returned as *token.Error along with
+// the token, io.EOF is returned at the end of input.  With SkipTrivia option space and comment
+// tokens without errors are skipped.
 func (l *Lexer) NextToken() (token.Token, error) {
+	for {
+		tok, err := l.nextToken()
+		if l.opts.SkipTrivia && err == nil && (tok.Typ == token.SpaceToken || tok.Typ == token.CommentToken) {
+			continue
+		}
+		return tok, err
+	}
+}
+
+func (l *Lexer) nextToken() (token.Token, error) {
+	if !l.started {
+		l.started = true
+		l.skipBOM()
+	}
 	r, err := l.peek()
 	if err != nil {
 		return token.Token{}, err
@@ -34,14 +102,44 @@ func (l *Lexer) NextToken() (token.Token, error) {
 	if err == io.EOF {
 		err = nil
 	}
+	if err == nil && l.err != nil {
+		err = l.err
+	}
+	l.err = nil
 	return tok, err
 }
 
+// error records a syntax error to be returned with the current token.  Only the first error is kept.
+func (l *Lexer) error(pos token.Position, code token.ErrorCode, format string, args ...interface{}) {
+	if l.err == nil {
+		l.err = token.Errorf(pos, code, format, args...)
+	}
+}
+
+// BOM reports whether the input starts with a byte order mark.  The mark is skipped, so that
+// the first token starts at offset 3 of the first line.  It is only valid after the first
+// call to NextToken.
+func (l *Lexer) BOM() bool {
+	return l.bom
+}
+
+// skipBOM skips the byte order mark at the start of input.
+func (l *Lexer) skipBOM() {
+	r, err := l.peek()
+	if err != nil || r != '\uFEFF' {
+		return
+	}
+	l.bom = true
+	l.startPos.Offset += l.next.size
+	l.endPos.Offset += l.next.size
+	l.next = lookahead{}
+}
+
 func (l *Lexer) nextState(r rune) (token.Token, error) {
-	if r == '#' {
+	if l.opts.isComment(r) {
 		return l.commentState()
 	}
-	if r == '\n' {
+	if l.isSep(r) {
 		return l.sepState()
 	}
 	if unicode.IsSpace(r) {
@@ -51,12 +149,21 @@ func (l *Lexer) nextState(r rune) (token.Token, error) {
 }
 
 func (l *Lexer) emit(typ token.TokenType) token.Token {
-	value := l.buffer.String()
+	value, raw := l.buffer.String(), ""
+	if l.reader == nil {
+		value = l.src[l.startPos.Offset:l.endPos.Offset]
+		if l.invalid {
+			value, raw = replaceInvalid(value), value
+		}
+		l.invalid = false
+	}
 	tok := token.Token{
 		Typ: typ,
 		Val: value,
 		Pos: l.startPos,
 		End: l.endPos,
+		Raw: raw,
+		Dialect: l.dialect,
 	}
 	l.startPos = l.endPos
 	l.buffer.Reset()
@@ -64,22 +171,68 @@ func (l *Lexer) emit(typ token.TokenType) token.Token {
 }
 
 func (l *Lexer) peek() (rune, error) {
-	r, size, err := l.next.r, l.next.size, l.next.err
-	if err != nil || size > 0 {
-		return r, err
+	if !l.next.empty() {
+		return l.next.r, l.next.err
 	}
-	r, size, err = l.reader.ReadRune()
-	l.next.r, l.next.size, l.next.err = r, size, err
+	if !l.after.empty() {
+		l.next, l.after = l.after, lookahead{}
+		return l.next.r, l.next.err
+	}
+	r, size, err := l.readRune()
+	l.next = lookahead{r: r, size: size, err: err}
 	return r, err
 }
 
+// readRune reads the next rune from the input.
+func (l *Lexer) readRune() (rune, int, error) {
+	if l.reader != nil {
+		return l.reader.ReadRune()
+	}
+	if l.srcPos >= len(l.src) {
+		return 0, 0, io.EOF
+	}
+	r, size := utf8.DecodeRuneInString(l.src[l.srcPos:])
+	l.srcPos += size
+	return r, size, nil
+}
+
+// peekAfter returns the rune following the peeked one.
+func (l *Lexer) peekAfter() (rune, error) {
+	if _, err := l.peek(); err != nil {
+		return 0, err
+	}
+	if l.after.empty() {
+		r, size, err := l.readRune()
+		l.after = lookahead{r: r, size: size, err: err}
+	}
+	return l.after.r, l.after.err
+}
+
+// isSep reports whether the peeked rune r starts a separator, i.e. it is a newline or
+// a carriage return followed by newline.  Lone carriage returns are spaces.
+func (l *Lexer) isSep(r rune) bool {
+	if r == '\r' {
+		r, err := l.peekAfter()
+		return err == nil && r == '\n'
+	}
+	return r == '\n'
+}
+
 func (l *Lexer) accept() {
 	r, size, err := l.next.r, l.next.size, l.next.err
 	if err != nil || size <= 0 {
 		// it's a bug: accept without peek or after error
 		panic("nothing to accept")
 	}
-	l.buffer.WriteRune(r)
+	if r == utf8.RuneError && size == 1 {
+		if !l.opts.ReplaceInvalidUTF8 {
+			l.error(l.endPos, token.InvalidUTF8, "invalid UTF-8 encoding")
+		}
+		l.invalid = true
+	}
+	if l.reader != nil {
+		l.buffer.WriteRune(r)
+	}
 	l.endPos.Offset += size
 	switch r {
 	case '\n':
@@ -103,17 +256,27 @@ func (l *Lexer) read() (rune, error) {
 
 func (l *Lexer) sepState() (token.Token, error) {
 	// assume separator (i.e. end of line)
-	l.accept()
+	l.acceptSep()
 	return l.emit(token.SepToken), nil
 }
 
+// acceptSep accepts the separator starting at the peeked rune.
+func (l *Lexer) acceptSep() {
+	r, _ := l.peek()
+	l.accept()
+	if r == '\r' {
+		l.peek()
+		l.accept()
+	}
+}
+
 func (l *Lexer) spacesState() (token.Token, error) {
 	for {
 		r, err := l.peek()
 		if err != nil {
 			return l.emit(token.SpaceToken), err
 		}
-		if !unicode.IsSpace(r) || r == '\n' {
+		if !unicode.IsSpace(r) || l.isSep(r) {
 			return l.emit(token.SpaceToken), nil
 		}
 		l.accept()
@@ -126,23 +289,29 @@ func (l *Lexer) textState() (token.Token, error) {
 		if err != nil {
 			return l.emit(token.TextToken), err
 		}
-		if unicode.IsSpace(r) || r == '#' {
+		if unicode.IsSpace(r) || l.opts.isComment(r) {
 			return l.emit(token.TextToken), nil
 		}
-		switch r {
-		case '\\':
+		switch {
+		case l.opts.isEscape(r):
 			// escape character
+			pos := l.endPos
 			l.accept()
-			r, err := l.read()
+			r, err := l.peek()
 			if err != nil {
+				if err == io.EOF {
+					l.error(pos, token.DanglingEscape, "escape sequence not terminated")
+				}
 				return l.emit(token.TextToken), err
 			}
 			// and terminate at the end of line
-			if r == '\n' {
+			if l.isSep(r) {
+				l.acceptSep()
 				return l.emit(token.TextToken), nil
 			}
+			l.accept()
 			continue
-		case '"':
+		case l.opts.isQuote(r):
 			l.accept()
 			err := quoteText(l, r)
 			if err != nil {
@@ -154,26 +323,45 @@ func (l *Lexer) textState() (token.Token, error) {
 	}
 }
 
+// quoteText consumes quoted text up to and including the closing quote.
+// Escapes are recognized in text quoted with Quotes only, text quoted with LiteralQuotes is
+// literal.  The opening quote must be already accepted.
 func quoteText(l *Lexer, quote rune) error {
+	quotePos := l.endPos
+	quotePos.Offset -= utf8.RuneLen(quote)
+	quotePos.Column--
 	for {
 		r, err := l.peek()
 		if err != nil {
 			// unterminated quoted thing
+			if err == io.EOF {
+				l.error(quotePos, token.UnterminatedQuote, "unterminated quoted string")
+			}
 			return err
 		}
-		switch r {
-		case '\\':
+		switch {
+		case l.opts.isEscape(r) && l.opts.isEscapedQuote(quote):
 			// escape character
+			pos := l.endPos
 			l.accept()
-			_, err := l.read()
+			r, err := l.peek()
 			if err != nil {
+				if err == io.EOF {
+					l.error(pos, token.DanglingEscape, "escape sequence not terminated")
+				}
 				return err
 			}
+			if l.isSep(r) {
+				l.acceptSep()
+				continue
+			}
+			l.accept()
 			continue
-		case '\n':
+		case l.isSep(r):
 			// terminate at end of line
+			l.error(quotePos, token.UnterminatedQuote, "unterminated quoted string")
 			return nil
-		case quote:
+		case r == quote:
 			// closing quote
 			l.accept()
 			return nil
@@ -188,9 +376,19 @@ func (l *Lexer) commentState() (token.Token, error) {
 		if err != nil {
 			return l.emit(token.CommentToken), err
 		}
-		if r == '\n' {
+		if l.isSep(r) {
 			return l.emit(token.CommentToken), nil
 		}
 		l.accept()
 	}
 }
+
+// replaceInvalid replaces each byte of invalid UTF-8 sequences in s with U+FFFD, like
+// io.RuneReader implementations do.
+func replaceInvalid(s string) string {
+	var b strings.Builder
+	for _, r := range s {
+		b.WriteRune(r)
+	}
+	return b.String()
+}
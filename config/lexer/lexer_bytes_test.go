@@ -0,0 +1,121 @@
+package lexer
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/tie/x/config/token"
+)
+
+// tokenResult is a token along with the error returned with it.
+type tokenResult struct {
+	Tok token.Token
+	Err error
+}
+
+func lexAll(l *Lexer) []tokenResult {
+	var results []tokenResult
+	for {
+		tok, err := l.NextToken()
+		if err == io.EOF {
+			return results
+		}
+		results = append(results, tokenResult{tok, err})
+	}
+}
+
+func TestLexerBytes(t *testing.T) {
+	inputs := []string{
+		"",
+		"on boot\n    start a # comment\n",
+		"service a /bin/a \"quoted \\\" arg\" 'single' \\\n    x\n",
+		"\uFEFFa\r\nb\rc \\\r\n",
+		"a\xffb \xfe\xfd # \xff\n",
+		"\"unterminated\n'also\nb \\",
+		"ünïcödé ✓\t x",
+	}
+	for _, input := range inputs {
+		want := lexAll(NewLexer("f", strings.NewReader(input)))
+		got := lexAll(NewLexerBytes("f", []byte(input)))
+		if len(got) != len(want) {
+			t.Errorf("%q: expected %d tokens, got %d", input, len(want), len(got))
+			continue
+		}
+		var src strings.Builder
+		for i := range want {
+			src.WriteString(got[i].Tok.Source())
+			// the reader does not keep the source of invalid sequences
+			got[i].Tok.Raw = ""
+			if got[i].Tok != want[i].Tok || fmt.Sprint(got[i].Err) != fmt.Sprint(want[i].Err) {
+				t.Errorf("%q: expected %s token with %v error, got %s token with %v error",
+					input, want[i].Tok, want[i].Err, got[i].Tok, got[i].Err)
+			}
+		}
+		if out := strings.TrimPrefix(input, "\uFEFF"); src.String() != out {
+			t.Errorf("%q: expected %q source of tokens, got %q", input, out, src.String())
+		}
+	}
+}
+
+func TestLexerBytesCopy(t *testing.T) {
+	src := []byte("start a")
+	l := NewLexerBytes("f", src)
+	tok, err := l.NextToken()
+	if err != nil {
+		t.Fatal(err)
+	}
+	copy(src, "xxxxxxx")
+	if tok.Val != "start" {
+		t.Fatalf("expected start token to be kept after source is modified, got %q", tok.Val)
+	}
+	if tok, _ := l.NextToken(); tok.Val != " " {
+		t.Fatalf("expected space token from the original source, got %q", tok.Val)
+	}
+}
+
+// benchmarkInput returns a config of roughly size bytes.
+func benchmarkInput(size int) []byte {
+	var b bytes.Buffer
+	for i := 0; b.Len() < size; i++ {
+		fmt.Fprintf(&b, "# service %d\n", i)
+		fmt.Fprintf(&b, "service svc%d /system/bin/svc%d --flag \"quoted arg\" 'literal'\n", i, i)
+		fmt.Fprintf(&b, "    class main\n    user system\n    group system inet\n")
+		fmt.Fprintf(&b, "    setenv PATH /system/bin:/vendor/bin \\\n        # folded\n\n")
+		fmt.Fprintf(&b, "on property:sys.svc%d=1 && boot\n    start svc%d\n\n", i, i)
+	}
+	return b.Bytes()
+}
+
+func benchmarkLexer(b *testing.B, src []byte, newLexer func([]byte) *Lexer) {
+	b.SetBytes(int64(len(src)))
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		l := newLexer(src)
+		for {
+			_, err := l.NextToken()
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				b.Fatalf("unexpected %s error", err)
+			}
+		}
+	}
+}
+
+func BenchmarkLexerReader(b *testing.B) {
+	src := benchmarkInput(4 << 20)
+	benchmarkLexer(b, src, func(src []byte) *Lexer {
+		return NewLexer("", bytes.NewReader(src))
+	})
+}
+
+func BenchmarkLexerBytes(b *testing.B) {
+	src := benchmarkInput(4 << 20)
+	benchmarkLexer(b, src, func(src []byte) *Lexer {
+		return NewLexerBytes("", src)
+	})
+}
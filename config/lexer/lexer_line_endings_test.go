@@ -0,0 +1,203 @@
+package lexer
+
+import (
+	"io"
+	"testing"
+
+	"github.com/tie/x/config/internal/testingh"
+	"github.com/tie/x/config/internal/tokenh"
+	"github.com/tie/x/config/token"
+)
+
+func TestLexerLineEndings(t *testing.T) {
+	RunLexerTests(t, []LexerTest{
+		{
+			Name: "CRLF",
+			Input: []testingh.ReadRune{
+				// "a\r\nb"
+				{Rune: 'a', Size: 1},
+				{Rune: '\r', Size: 1},
+				{Rune: '\n', Size: 1},
+				{Rune: 'b', Size: 1},
+				{Error: io.EOF},
+			},
+			Passes: []LexerTestPass{
+				expectTokens(
+					tokenh.Text("a", "1:1(+0)", "1:2(+1)"),
+					tokenh.Sep("\r\n", "1:2(+1)", "2:1(+3)"),
+					tokenh.Text("b", "2:1(+3)", "2:2(+4)"),
+				),
+				expectEOF,
+			},
+		},
+		{
+			Name: "LoneCR",
+			Input: []testingh.ReadRune{
+				// "a\rb"
+				{Rune: 'a', Size: 1},
+				{Rune: '\r', Size: 1},
+				{Rune: 'b', Size: 1},
+				{Error: io.EOF},
+			},
+			Passes: []LexerTestPass{
+				expectTokens(
+					tokenh.Text("a", "1:1(+0)", "1:2(+1)"),
+					tokenh.Space("\r", "1:2(+1)", "1:3(+2)"),
+					tokenh.Text("b", "1:3(+2)", "1:4(+3)"),
+				),
+				expectEOF,
+			},
+		},
+		{
+			Name: "CRAtEOF",
+			Input: []testingh.ReadRune{
+				// "a\r"
+				{Rune: 'a', Size: 1},
+				{Rune: '\r', Size: 1},
+				{Error: io.EOF},
+			},
+			Passes: []LexerTestPass{
+				expectTokens(
+					tokenh.Text("a", "1:1(+0)", "1:2(+1)"),
+					tokenh.Space("\r", "1:2(+1)", "1:3(+2)"),
+				),
+				expectEOF,
+			},
+		},
+		{
+			Name: "SpaceCRLF",
+			Input: []testingh.ReadRune{
+				// " \r\r\n"
+				{Rune: ' ', Size: 1},
+				{Rune: '\r', Size: 1},
+				{Rune: '\r', Size: 1},
+				{Rune: '\n', Size: 1},
+				{Error: io.EOF},
+			},
+			Passes: []LexerTestPass{
+				expectTokens(
+					tokenh.Space(" \r", "1:1(+0)", "1:3(+2)"),
+					tokenh.Sep("\r\n", "1:3(+2)", "2:1(+4)"),
+				),
+				expectEOF,
+			},
+		},
+		{
+			Name: "CommentCRLF",
+			Input: []testingh.ReadRune{
+				// "#c\r\n"
+				{Rune: '#', Size: 1},
+				{Rune: 'c', Size: 1},
+				{Rune: '\r', Size: 1},
+				{Rune: '\n', Size: 1},
+				{Error: io.EOF},
+			},
+			Passes: []LexerTestPass{
+				expectTokens(
+					tokenh.Comment("#c", "1:1(+0)", "1:3(+2)"),
+					tokenh.Sep("\r\n", "1:3(+2)", "2:1(+4)"),
+				),
+				expectEOF,
+			},
+		},
+		{
+			Name: "FoldingCRLF",
+			Input: []testingh.ReadRune{
+				// "a\\\r\nb"
+				{Rune: 'a', Size: 1},
+				{Rune: '\\', Size: 1},
+				{Rune: '\r', Size: 1},
+				{Rune: '\n', Size: 1},
+				{Rune: 'b', Size: 1},
+				{Error: io.EOF},
+			},
+			Passes: []LexerTestPass{
+				expectTokens(
+					tokenh.Text("a\\\r\n", "1:1(+0)", "2:1(+4)"),
+					tokenh.Text("b", "2:1(+4)", "2:2(+5)"),
+				),
+				expectEOF,
+			},
+		},
+		{
+			Name: "QuoteEscapedCRLF",
+			Input: []testingh.ReadRune{
+				// "\"\\\r\n\""
+				{Rune: '"', Size: 1},
+				{Rune: '\\', Size: 1},
+				{Rune: '\r', Size: 1},
+				{Rune: '\n', Size: 1},
+				{Rune: '"', Size: 1},
+				{Error: io.EOF},
+			},
+			Passes: []LexerTestPass{
+				expectTokens(
+					tokenh.Text("\"\\\r\n\"", "1:1(+0)", "2:2(+5)"),
+				),
+				expectEOF,
+			},
+		},
+		{
+			Name: "UnterminatedQuoteCRLF",
+			Input: []testingh.ReadRune{
+				// "\"a\r\n"
+				{Rune: '"', Size: 1},
+				{Rune: 'a', Size: 1},
+				{Rune: '\r', Size: 1},
+				{Rune: '\n', Size: 1},
+				{Error: io.EOF},
+			},
+			Passes: []LexerTestPass{
+				expectTokenError(
+					tokenh.Text("\"a", "1:1(+0)", "1:3(+2)"),
+					tokenh.Error(token.UnterminatedQuote, "1:1(+0)"),
+				),
+				expectTokens(
+					tokenh.Sep("\r\n", "1:3(+2)", "2:1(+4)"),
+				),
+				expectEOF,
+			},
+		},
+		{
+			Name: "BOM",
+			Input: []testingh.ReadRune{
+				// "\uFEFFa"
+				{Rune: '\uFEFF', Size: 3},
+				{Rune: 'a', Size: 1},
+				{Error: io.EOF},
+			},
+			Passes: []LexerTestPass{
+				expectTokens(
+					tokenh.Text("a", "1:1(+3)", "1:2(+4)"),
+				),
+				expectEOF,
+			},
+		},
+		{
+			Name: "BOMOnly",
+			Input: []testingh.ReadRune{
+				// "\uFEFF"
+				{Rune: '\uFEFF', Size: 3},
+				{Error: io.EOF},
+			},
+			Passes: []LexerTestPass{
+				expectEOF,
+			},
+		},
+		{
+			Name: "BOMNotAtStart",
+			Input: []testingh.ReadRune{
+				// "a\uFEFF"
+				{Rune: 'a', Size: 1},
+				{Rune: '\uFEFF', Size: 3},
+				{Error: io.EOF},
+			},
+			Passes: []LexerTestPass{
+				expectTokens(
+					tokenh.Text("a\uFEFF", "1:1(+0)", "1:3(+4)"),
+				),
+				expectEOF,
+			},
+		},
+	})
+}
@@ -3,9 +3,11 @@ package lexer
 import (
 	"io"
 	"testing"
+	"unicode/utf8"
 
 	"github.com/tie/x/config/internal/testingh"
 	"github.com/tie/x/config/internal/tokenh"
+	"github.com/tie/x/config/token"
 )
 
 func TestLexerMisc(t *testing.T) {
@@ -34,5 +36,94 @@ func TestLexerMisc(t *testing.T) {
 				expectEOF,
 			},
 		},
+		{
+			Name: "Filename",
+			Filename: "init.rc",
+			Input: []testingh.ReadRune{
+				// "a\nb"
+				{Rune: 'a', Size: 1},
+				{Rune: '\n', Size: 1},
+				{Rune: 'b', Size: 1},
+				{Error: io.EOF},
+			},
+			Passes: []LexerTestPass{
+				expectTokens(
+					tokenh.Text("a", "init.rc:1:1(+0)", "init.rc:1:2(+1)"),
+					tokenh.Sep("\n", "init.rc:1:2(+1)", "init.rc:2:1(+2)"),
+					tokenh.Text("b", "init.rc:2:1(+2)", "init.rc:2:2(+3)"),
+				),
+				expectEOF,
+			},
+		},
+		{
+			Name: "InvalidUTF8",
+			Input: []testingh.ReadRune{
+				// "a\xffb c"
+				{Rune: 'a', Size: 1},
+				{Rune: utf8.RuneError, Size: 1},
+				{Rune: 'b', Size: 1},
+				{Rune: ' ', Size: 1},
+				{Rune: 'c', Size: 1},
+				{Error: io.EOF},
+			},
+			Passes: []LexerTestPass{
+				expectTokenError(
+					tokenh.Text("a\uFFFDb", "1:1(+0)", "1:4(+3)"),
+					tokenh.Error(token.InvalidUTF8, "1:2(+1)"),
+				),
+				expectTokens(
+					tokenh.Space(" ", "1:4(+3)", "1:5(+4)"),
+					tokenh.Text("c", "1:5(+4)", "1:6(+5)"),
+				),
+				expectEOF,
+			},
+		},
+		{
+			Name: "InvalidUTF8Comment",
+			Input: []testingh.ReadRune{
+				// "#\xff"
+				{Rune: '#', Size: 1},
+				{Rune: utf8.RuneError, Size: 1},
+				{Error: io.EOF},
+			},
+			Passes: []LexerTestPass{
+				expectTokenError(
+					tokenh.Comment("#\uFFFD", "1:1(+0)", "1:3(+2)"),
+					tokenh.Error(token.InvalidUTF8, "1:2(+1)"),
+				),
+				expectEOF,
+			},
+		},
+		{
+			Name: "ValidRuneError",
+			Input: []testingh.ReadRune{
+				// "\uFFFD"
+				{Rune: utf8.RuneError, Size: 3},
+				{Error: io.EOF},
+			},
+			Passes: []LexerTestPass{
+				expectTokens(
+					tokenh.Text("\uFFFD", "1:1(+0)", "1:2(+3)"),
+				),
+				expectEOF,
+			},
+		},
+		{
+			Name: "ReplaceInvalidUTF8",
+			Options: Options{ReplaceInvalidUTF8: true},
+			Input: []testingh.ReadRune{
+				// "a\xff\xfe"
+				{Rune: 'a', Size: 1},
+				{Rune: utf8.RuneError, Size: 1},
+				{Rune: utf8.RuneError, Size: 1},
+				{Error: io.EOF},
+			},
+			Passes: []LexerTestPass{
+				expectTokens(
+					tokenh.Text("a\uFFFD\uFFFD", "1:1(+0)", "1:4(+3)"),
+				),
+				expectEOF,
+			},
+		},
 	})
 }
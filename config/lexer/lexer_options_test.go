@@ -0,0 +1,133 @@
+package lexer
+
+import (
+	"io"
+	"testing"
+
+	"github.com/tie/x/config/internal/testingh"
+	"github.com/tie/x/config/internal/tokenh"
+)
+
+// runes returns ReadRune values of ASCII string s followed by EOF.
+func runes(s string) []testingh.ReadRune {
+	var seq []testingh.ReadRune
+	for _, r := range s {
+		seq = append(seq, testingh.ReadRune{Rune: r, Size: 1})
+	}
+	return append(seq, testingh.ReadRune{Error: io.EOF})
+}
+
+func TestLexerOptions(t *testing.T) {
+	RunLexerTests(t, []LexerTest{
+		{
+			Name: "Comments",
+			Options: Options{Comments: []rune{';', '!'}},
+			Input: runes("a#b;c\n!d"),
+			Passes: []LexerTestPass{
+				expectTokens(
+					tokenh.Text("a#b", "1:1(+0)", "1:4(+3)"),
+					tokenh.Comment(";c", "1:4(+3)", "1:6(+5)"),
+					tokenh.Sep("\n", "1:6(+5)", "2:1(+6)"),
+					tokenh.Comment("!d", "2:1(+6)", "2:3(+8)"),
+				),
+				expectEOF,
+			},
+		},
+		{
+			Name: "NoComments",
+			Options: Options{Comments: []rune{}},
+			Input: runes("a #b"),
+			Passes: []LexerTestPass{
+				expectTokens(
+					tokenh.Text("a", "1:1(+0)", "1:2(+1)"),
+					tokenh.Space(" ", "1:2(+1)", "1:3(+2)"),
+					tokenh.Text("#b", "1:3(+2)", "1:5(+4)"),
+				),
+				expectEOF,
+			},
+		},
+		{
+			Name: "Quotes",
+			Options: Options{Quotes: []rune{'`'}, LiteralQuotes: []rune{}},
+			Input: runes("`a \\` b` 'c d'"),
+			Passes: []LexerTestPass{
+				expectTokens(
+					tokenh.Text("`a \\` b`", "1:1(+0)", "1:9(+8)"),
+					tokenh.Space(" ", "1:9(+8)", "1:10(+9)"),
+					tokenh.Text("'c", "1:10(+9)", "1:12(+11)"),
+					tokenh.Space(" ", "1:12(+11)", "1:13(+12)"),
+					tokenh.Text("d'", "1:13(+12)", "1:15(+14)"),
+				),
+				expectEOF,
+			},
+		},
+		{
+			Name: "LiteralQuotes",
+			Options: Options{Quotes: []rune{}, LiteralQuotes: []rune{'"'}},
+			Input: runes("\"a\\\" b"),
+			Passes: []LexerTestPass{
+				expectTokens(
+					tokenh.Text("\"a\\\"", "1:1(+0)", "1:5(+4)"),
+					tokenh.Space(" ", "1:5(+4)", "1:6(+5)"),
+					tokenh.Text("b", "1:6(+5)", "1:7(+6)"),
+				),
+				expectEOF,
+			},
+		},
+		{
+			Name: "Escape",
+			Options: Options{Escape: '^'},
+			Input: runes("a^ b\\ c^\nd"),
+			Passes: []LexerTestPass{
+				expectTokens(
+					tokenh.Text("a^ b\\", "1:1(+0)", "1:6(+5)"),
+					tokenh.Space(" ", "1:6(+5)", "1:7(+6)"),
+					tokenh.Text("c^\n", "1:7(+6)", "2:1(+9)"),
+					tokenh.Text("d", "2:1(+9)", "2:2(+10)"),
+				),
+				expectEOF,
+			},
+		},
+		{
+			Name: "NoEscape",
+			Options: Options{Escape: NoEscape},
+			Input: runes("a\\ \"b\\\""),
+			Passes: []LexerTestPass{
+				expectTokens(
+					tokenh.Text("a\\", "1:1(+0)", "1:3(+2)"),
+					tokenh.Space(" ", "1:3(+2)", "1:4(+3)"),
+					tokenh.Text("\"b\\\"", "1:4(+3)", "1:8(+7)"),
+				),
+				expectEOF,
+			},
+		},
+		{
+			Name: "SkipTrivia",
+			Options: Options{SkipTrivia: true},
+			Input: runes("  a b # c\n# d\n\te"),
+			Passes: []LexerTestPass{
+				expectTokens(
+					tokenh.Text("a", "1:3(+2)", "1:4(+3)"),
+					tokenh.Text("b", "1:5(+4)", "1:6(+5)"),
+					tokenh.Sep("\n", "1:10(+9)", "2:1(+10)"),
+					tokenh.Sep("\n", "2:4(+13)", "3:1(+14)"),
+					tokenh.Text("e", "3:2(+15)", "3:3(+16)"),
+				),
+				expectEOF,
+			},
+		},
+	})
+}
+
+func TestOptionsDialect(t *testing.T) {
+	if d := (Options{}).Dialect(); d != nil {
+		t.Errorf("expected default dialect, got %v", d)
+	}
+	if d := (Options{Quotes: []rune{'"'}, Escape: '\\'}).Dialect(); d != nil {
+		t.Errorf("expected default dialect, got %v", d)
+	}
+	d := (Options{Escape: NoEscape}).Dialect()
+	if d == nil || d.Escape != NoEscape || len(d.Quotes) != 1 || d.Quotes[0] != '"' {
+		t.Errorf("unexpected %v dialect", d)
+	}
+}
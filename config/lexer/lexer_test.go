@@ -11,6 +11,8 @@ import (
 type (
 	LexerTest struct {
 		Name string
+		Filename string
+		Options Options
 		Input []testingh.ReadRune
 		Passes []LexerTestPass
 	}
@@ -20,7 +22,7 @@ type (
 func RunLexerTests(t *testing.T, cases []LexerTest) {
 	for _, c := range cases {
 		r := testingh.NewRuneReader(c.Input)
-		l, passes := NewLexer(r), c.Passes
+		l, passes := NewLexerOptions(c.Filename, r, c.Options), c.Passes
 		t.Run(c.Name, func(t *testing.T) {
 			for _, pass := range passes {
 				pass(t, l)
@@ -36,6 +38,10 @@ func expectTokens(toks ...token.Token) LexerTestPass {
 			if err != nil {
 				t.Fatalf("expected %s token, got %s error", tok, err)
 			}
+			if ntok.Dialect != l.dialect {
+				t.Fatalf("expected %v dialect, got %v", l.dialect, ntok.Dialect)
+			}
+			ntok.Dialect = nil
 			if ntok != tok {
 				t.Fatalf("expected %s token, got %s token", tok, ntok)
 			}
@@ -43,6 +49,27 @@ func expectTokens(toks ...token.Token) LexerTestPass {
 	}
 }
 
+// expectTokenError expects a token along with a syntax error.  Error messages are not compared.
+func expectTokenError(tok token.Token, want *token.Error) LexerTestPass {
+	return func(t *testing.T, l *Lexer) {
+		ntok, err := l.NextToken()
+		e, ok := err.(*token.Error)
+		if !ok {
+			t.Fatalf("expected %s token with %s error at %s, got %s token with %v error", tok, want.Code, want.Pos, ntok, err)
+		}
+		if e.Code != want.Code || e.Pos != want.Pos {
+			t.Fatalf("expected %s error at %s, got %s error at %s", want.Code, want.Pos, e.Code, e.Pos)
+		}
+		if ntok.Dialect != l.dialect {
+			t.Fatalf("expected %v dialect, got %v", l.dialect, ntok.Dialect)
+		}
+		ntok.Dialect = nil
+		if ntok != tok {
+			t.Fatalf("expected %s token, got %s token", tok, ntok)
+		}
+	}
+}
+
 func expectEOF(t *testing.T, l *Lexer) {
 	tok, err := l.NextToken()
 	if err != io.EOF {
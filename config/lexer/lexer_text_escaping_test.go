@@ -6,6 +6,7 @@ import (
 
 	"github.com/tie/x/config/internal/testingh"
 	"github.com/tie/x/config/internal/tokenh"
+	"github.com/tie/x/config/token"
 )
 
 func TestLexerTextEscaping(t *testing.T) {
@@ -18,8 +19,9 @@ func TestLexerTextEscaping(t *testing.T) {
 				{Error: io.EOF},
 			},
 			Passes: []LexerTestPass{
-				expectTokens(
+				expectTokenError(
 					tokenh.Text("\\", "1:1(+0)", "1:2(+1)"),
+					tokenh.Error(token.DanglingEscape, "1:1(+0)"),
 				),
 				expectEOF,
 			},
@@ -6,6 +6,7 @@ import (
 
 	"github.com/tie/x/config/internal/testingh"
 	"github.com/tie/x/config/internal/tokenh"
+	"github.com/tie/x/config/token"
 )
 
 func TestLexerTextQuotes(t *testing.T) {
@@ -18,8 +19,9 @@ func TestLexerTextQuotes(t *testing.T) {
 				{Error: io.EOF},
 			},
 			Passes: []LexerTestPass{
-				expectTokens(
+				expectTokenError(
 					tokenh.Text("\"", "1:1(+0)", "1:2(+1)"),
+					tokenh.Error(token.UnterminatedQuote, "1:1(+0)"),
 				),
 				expectEOF,
 			},
@@ -49,10 +51,16 @@ func TestLexerTextQuotes(t *testing.T) {
 				{Error: io.EOF},
 			},
 			Passes: []LexerTestPass{
-				expectTokens(
+				expectTokenError(
 					tokenh.Text("\"", "1:1(+0)", "1:2(+1)"),
+					tokenh.Error(token.UnterminatedQuote, "1:1(+0)"),
+				),
+				expectTokens(
 					tokenh.Sep("\n", "1:2(+1)", "2:1(+2)"),
+				),
+				expectTokenError(
 					tokenh.Text("\"", "2:1(+2)", "2:2(+3)"),
+					tokenh.Error(token.UnterminatedQuote, "2:1(+2)"),
 				),
 				expectEOF,
 			},
@@ -119,8 +127,9 @@ func TestLexerTextQuotes(t *testing.T) {
 				{Error: io.EOF},
 			},
 			Passes: []LexerTestPass{
-				expectTokens(
+				expectTokenError(
 					tokenh.Text("\"\\", "1:1(+0)", "1:3(+2)"),
+					tokenh.Error(token.DanglingEscape, "1:2(+1)"),
 				),
 				expectEOF,
 			},
@@ -146,7 +155,10 @@ func TestLexerTextQuotes(t *testing.T) {
 					tokenh.Space(" ", "1:4(+3)", "1:5(+4)"),
 					tokenh.Text("\" \"", "1:5(+4)", "1:8(+7)"),
 					tokenh.Space(" ", "1:8(+7)", "1:9(+8)"),
+				),
+				expectTokenError(
 					tokenh.Text("\"", "1:9(+8)", "1:10(+9)"),
+					tokenh.Error(token.UnterminatedQuote, "1:9(+8)"),
 				),
 				expectEOF,
 			},
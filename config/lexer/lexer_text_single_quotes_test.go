@@ -0,0 +1,161 @@
+package lexer
+
+import (
+	"io"
+	"testing"
+
+	"github.com/tie/x/config/internal/testingh"
+	"github.com/tie/x/config/internal/tokenh"
+	"github.com/tie/x/config/token"
+)
+
+func TestLexerTextSingleQuotes(t *testing.T) {
+	RunLexerTests(t, []LexerTest{
+		{
+			Name: "EOF",
+			Input: []testingh.ReadRune{
+				// "'"
+				{Rune: '\'', Size: 1},
+				{Error: io.EOF},
+			},
+			Passes: []LexerTestPass{
+				expectTokenError(
+					tokenh.Text("'", "1:1(+0)", "1:2(+1)"),
+					tokenh.Error(token.UnterminatedQuote, "1:1(+0)"),
+				),
+				expectEOF,
+			},
+		},
+		{
+			Name: "Empty",
+			Input: []testingh.ReadRune{
+				// "''"
+				{Rune: '\'', Size: 1},
+				{Rune: '\'', Size: 1},
+				{Error: io.EOF},
+			},
+			Passes: []LexerTestPass{
+				expectTokens(
+					tokenh.Text("''", "1:1(+0)", "1:3(+2)"),
+				),
+				expectEOF,
+			},
+		},
+		{
+			Name: "Sep",
+			Input: []testingh.ReadRune{
+				// "'\n'"
+				{Rune: '\'', Size: 1},
+				{Rune: '\n', Size: 1},
+				{Rune: '\'', Size: 1},
+				{Error: io.EOF},
+			},
+			Passes: []LexerTestPass{
+				expectTokenError(
+					tokenh.Text("'", "1:1(+0)", "1:2(+1)"),
+					tokenh.Error(token.UnterminatedQuote, "1:1(+0)"),
+				),
+				expectTokens(
+					tokenh.Sep("\n", "1:2(+1)", "2:1(+2)"),
+				),
+				expectTokenError(
+					tokenh.Text("'", "2:1(+2)", "2:2(+3)"),
+					tokenh.Error(token.UnterminatedQuote, "2:1(+2)"),
+				),
+				expectEOF,
+			},
+		},
+		{
+			Name: "TextWithSpaces",
+			Input: []testingh.ReadRune{
+				// "' a '"
+				{Rune: '\'', Size: 1},
+				{Rune: ' ', Size: 1},
+				{Rune: 'a', Size: 1},
+				{Rune: ' ', Size: 1},
+				{Rune: '\'', Size: 1},
+				{Error: io.EOF},
+			},
+			Passes: []LexerTestPass{
+				expectTokens(
+					tokenh.Text("' a '", "1:1(+0)", "1:6(+5)"),
+				),
+				expectEOF,
+			},
+		},
+		// backslash is literal, so it does not escape the closing quote
+		{
+			Name: "Backslash",
+			Input: []testingh.ReadRune{
+				// "'\\' a"
+				{Rune: '\'', Size: 1},
+				{Rune: '\\', Size: 1},
+				{Rune: '\'', Size: 1},
+				{Rune: ' ', Size: 1},
+				{Rune: 'a', Size: 1},
+				{Error: io.EOF},
+			},
+			Passes: []LexerTestPass{
+				expectTokens(
+					tokenh.Text("'\\'", "1:1(+0)", "1:4(+3)"),
+					tokenh.Space(" ", "1:4(+3)", "1:5(+4)"),
+					tokenh.Text("a", "1:5(+4)", "1:6(+5)"),
+				),
+				expectEOF,
+			},
+		},
+		{
+			Name: "DoubleQuote",
+			Input: []testingh.ReadRune{
+				// "'\" '"
+				{Rune: '\'', Size: 1},
+				{Rune: '"', Size: 1},
+				{Rune: ' ', Size: 1},
+				{Rune: '\'', Size: 1},
+				{Error: io.EOF},
+			},
+			Passes: []LexerTestPass{
+				expectTokens(
+					tokenh.Text("'\" '", "1:1(+0)", "1:5(+4)"),
+				),
+				expectEOF,
+			},
+		},
+		{
+			Name: "InsideDoubleQuotes",
+			Input: []testingh.ReadRune{
+				// "\"' \""
+				{Rune: '"', Size: 1},
+				{Rune: '\'', Size: 1},
+				{Rune: ' ', Size: 1},
+				{Rune: '"', Size: 1},
+				{Error: io.EOF},
+			},
+			Passes: []LexerTestPass{
+				expectTokens(
+					tokenh.Text("\"' \"", "1:1(+0)", "1:5(+4)"),
+				),
+				expectEOF,
+			},
+		},
+		{
+			Name: "Escaped",
+			Input: []testingh.ReadRune{
+				// "\\' a"
+				{Rune: '\\', Size: 1},
+				{Rune: '\'', Size: 1},
+				{Rune: ' ', Size: 1},
+				{Rune: 'a', Size: 1},
+				{Error: io.EOF},
+			},
+			Passes: []LexerTestPass{
+				expectTokens(
+					tokenh.Text("\\'", "1:1(+0)", "1:3(+2)"),
+					tokenh.Space(" ", "1:3(+2)", "1:4(+3)"),
+					tokenh.Text("a", "1:4(+3)", "1:5(+4)"),
+				),
+				expectEOF,
+			},
+		},
+	})
+}
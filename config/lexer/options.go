@@ -0,0 +1,86 @@
+package lexer
+
+import (
+	"github.com/tie/x/config/token"
+)
+
+// NoEscape disables escape sequences when used as Options.Escape.
+const NoEscape rune = -1
+
+// Options configure the lexer.  The zero value is the default configuration of init config
+// files.  Nil rune sets select the default runes, and empty non-nil sets disable the feature.
+type Options struct {
+	// Comments are runes that start comments, '#' by default.
+	Comments []rune
+	// Quotes are quote runes of text with escape sequences, '"' by default.
+	Quotes []rune
+	// LiteralQuotes are quote runes of literal text, '\'' by default.
+	LiteralQuotes []rune
+	// Escape is the rune that starts escape sequences and line folding, '\\' by default.
+	Escape rune
+	// SkipTrivia makes the lexer skip space and comment tokens.  Tokens with errors are
+	// returned anyway.
+	SkipTrivia bool
+	// ReplaceInvalidUTF8 makes the lexer silently replace invalid UTF-8 sequences with U+FFFD.
+	// By default invalid sequences are replaced too, but also reported as InvalidUTF8 errors.
+	ReplaceInvalidUTF8 bool
+}
+
+// withDefaults returns options with default values set.
+func (o Options) withDefaults() Options {
+	if o.Comments == nil {
+		o.Comments = []rune{'#'}
+	}
+	if o.Quotes == nil {
+		o.Quotes = []rune{'"'}
+	}
+	if o.LiteralQuotes == nil {
+		o.LiteralQuotes = []rune{'\''}
+	}
+	if o.Escape == 0 {
+		o.Escape = '\\'
+	}
+	return o
+}
+
+// Dialect returns the quoting dialect of text tokens lexed with the options, or nil if it is
+// the default dialect.
+func (o Options) Dialect() *token.Dialect {
+	o = o.withDefaults()
+	d := &token.Dialect{
+		Comments: o.Comments,
+		Quotes: o.Quotes,
+		LiteralQuotes: o.LiteralQuotes,
+		Escape: o.Escape,
+	}
+	if d.IsDefault() {
+		return nil
+	}
+	return d
+}
+
+func (o *Options) isComment(r rune) bool {
+	return contains(o.Comments, r)
+}
+
+func (o *Options) isQuote(r rune) bool {
+	return contains(o.Quotes, r) || contains(o.LiteralQuotes, r)
+}
+
+// isEscapedQuote reports whether escapes are recognized in text quoted with r.
+func (o *Options) isEscapedQuote(r rune) bool {
+	return contains(o.Quotes, r)
+}
+
+func (o *Options) isEscape(r rune) bool {
+	return o.Escape != NoEscape && r == o.Escape
+}
+
+func contains(runes []rune, r rune) bool {
+	for _, c := range runes {
+		if c == r {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,197 @@
+package config
+
+import (
+	"bytes"
+	"io/fs"
+	"path"
+	"strings"
+
+	"github.com/tie/x/config/lexer"
+	"github.com/tie/x/config/parser"
+	"github.com/tie/x/config/token"
+)
+
+// Loader loads config files along with the files they import.
+type Loader struct {
+	// FS is the file system to load files from.  Its root is the root of absolute import paths.
+	FS fs.FS
+	// Properties are used to expand property references in import paths, see Expand.  May be nil.
+	Properties Properties
+	// Mode is the parser mode.  In parser.AllErrors mode loading continues after errors.
+	Mode parser.Mode
+	// Lexer configures the lexer dialect of files, see ParseOptions.
+	Lexer lexer.Options
+}
+
+// Load parses the named files and, recursively, all files they import.
+//
+// Import paths are resolved relative to the directory of the importing file, absolute
+// paths are resolved relative to the root of the file system.  Paths may contain glob
+// patterns, and directories are expanded to all *.rc files they contain in lexical order.
+// Each file is loaded only once, even if it is named several times or is also imported by
+// another named file.  Files are returned in processing order, i.e. imports of a file follow
+// the file itself, in the order of import statements.
+//
+// Errors are returned as *token.Error or, in parser.AllErrors mode, as token.ErrorList.
+// Failure to read a named file itself is returned as is, and loading stops.
+func (l *Loader) Load(names ...string) ([]*File, error) {
+	ld := &loader{
+		Loader: l,
+		loaded: map[string]bool{},
+	}
+	for _, name := range names {
+		name = path.Clean(strings.TrimPrefix(name, "/"))
+		if ld.loaded[name] {
+			continue
+		}
+		src, err := fs.ReadFile(l.FS, name)
+		if err != nil {
+			return ld.files, err
+		}
+		ld.loaded[name] = true
+		ld.load(name, src, nil)
+		if ld.failed() {
+			break
+		}
+	}
+	if l.Mode&parser.AllErrors != 0 {
+		ld.errs.Sort()
+		return ld.files, ld.errs.Err()
+	}
+	if len(ld.errs) > 0 {
+		return ld.files, ld.errs[0]
+	}
+	return ld.files, nil
+}
+
+// loader is the state of a single Load call.
+type loader struct {
+	*Loader
+	files []*File
+	loaded map[string]bool
+	errs token.ErrorList
+}
+
+// failed reports whether loading should stop.
+func (ld *loader) failed() bool {
+	return len(ld.errs) > 0 && ld.Mode&parser.AllErrors == 0
+}
+
+func (ld *loader) error(err error) {
+	if errs, ok := err.(token.ErrorList); ok {
+		ld.errs = append(ld.errs, errs...)
+		return
+	}
+	ld.errs.Add(err)
+}
+
+// load parses the file and loads its imports.  Chain is the sequence of files importing this one.
+func (ld *loader) load(name string, src []byte, chain []string) {
+	f, err := ParseOptions(name, bytes.NewReader(src), ld.Mode, ld.Lexer)
+	ld.files = append(ld.files, f)
+	if err != nil {
+		ld.error(err)
+		if ld.failed() {
+			return
+		}
+	}
+	chain = append(chain[:len(chain):len(chain)], name)
+	for _, imp := range f.Imports {
+		names, err := ld.resolve(name, imp)
+		if err != nil {
+			ld.error(err)
+			if ld.failed() {
+				return
+			}
+			continue
+		}
+		for _, next := range names {
+			if cycle := importCycle(chain, next); cycle != "" {
+				ld.error(token.Errorf(imp.Pos, token.InvalidImport, "import cycle: %s", cycle))
+				if ld.failed() {
+					return
+				}
+				continue
+			}
+			if ld.loaded[next] {
+				continue
+			}
+			ld.loaded[next] = true
+			src, err := fs.ReadFile(ld.FS, next)
+			if err != nil {
+				ld.error(token.Errorf(imp.Pos, token.InvalidImport, "import %s: %s", next, unwrapPathError(err)))
+				if ld.failed() {
+					return
+				}
+				continue
+			}
+			ld.load(next, src, chain)
+			if ld.failed() {
+				return
+			}
+		}
+	}
+}
+
+// resolve returns names of files imported by the import statement.
+func (ld *loader) resolve(importer string, imp *Import) ([]string, error) {
+	p, err := Expand(imp.Path, ld.Properties)
+	if err != nil {
+		return nil, token.Errorf(imp.Pos, token.InvalidImport, "import %s: %s", imp.Path, err)
+	}
+	if strings.HasPrefix(p, "/") {
+		p = path.Clean(p[1:])
+	} else {
+		p = path.Join(path.Dir(importer), p)
+	}
+	targets := []string{p}
+	if strings.ContainsAny(p, "*?[") {
+		targets, err = fs.Glob(ld.FS, p)
+		if err != nil {
+			return nil, token.Errorf(imp.Pos, token.InvalidImport, "import %s: %s", imp.Path, err)
+		}
+		if len(targets) == 0 {
+			return nil, token.Errorf(imp.Pos, token.InvalidImport, "import %s: no matching files", imp.Path)
+		}
+	}
+	var names []string
+	for _, target := range targets {
+		info, err := fs.Stat(ld.FS, target)
+		if err != nil {
+			return nil, token.Errorf(imp.Pos, token.InvalidImport, "import %s: %s", imp.Path, unwrapPathError(err))
+		}
+		if !info.IsDir() {
+			names = append(names, target)
+			continue
+		}
+		entries, err := fs.ReadDir(ld.FS, target)
+		if err != nil {
+			return nil, token.Errorf(imp.Pos, token.InvalidImport, "import %s: %s", imp.Path, unwrapPathError(err))
+		}
+		for _, entry := range entries {
+			if !entry.IsDir() && path.Ext(entry.Name()) == ".rc" {
+				names = append(names, path.Join(target, entry.Name()))
+			}
+		}
+	}
+	return names, nil
+}
+
+// importCycle returns the import chain formatted as a cycle if the name is already in the chain.
+func importCycle(chain []string, name string) string {
+	for i, n := range chain {
+		if n == name {
+			return strings.Join(append(chain[i:len(chain):len(chain)], name), " -> ")
+		}
+	}
+	return ""
+}
+
+// unwrapPathError strips the operation and path from fs errors, since the path is already
+// mentioned in import errors.
+func unwrapPathError(err error) error {
+	if e, ok := err.(*fs.PathError); ok {
+		return e.Err
+	}
+	return err
+}
@@ -0,0 +1,136 @@
+package config
+
+import (
+	"strings"
+	"testing"
+	"testing/fstest"
+
+	"github.com/tie/x/config/parser"
+	"github.com/tie/x/config/token"
+)
+
+func TestLoad(t *testing.T) {
+	fsys := fstest.MapFS{
+		"init.rc": {Data: []byte(strings.Join([]string{
+			"import /init.${ro.hardware}.rc",
+			"import etc/init",
+			"import vendor/*/init.rc",
+			"import etc/init/b.rc",
+		}, "\n"))},
+		"init.goldfish.rc": {Data: []byte("import etc/init/b.rc\n")},
+		"etc/init/b.rc": {Data: []byte("service b /bin/b\n")},
+		"etc/init/a.rc": {Data: []byte("service a /bin/a\nimport ../../misc.rc\n")},
+		"etc/init/readme.txt": {Data: []byte("not a config\n")},
+		"misc.rc": {Data: []byte("on boot\n    start a\n")},
+		"vendor/x/init.rc": {Data: []byte("service x /bin/x\n")},
+		"vendor/y/init.rc": {Data: []byte("service y /bin/y\n")},
+	}
+	l := &Loader{
+		FS: fsys,
+		Properties: PropertyMap{"ro.hardware": "goldfish"},
+	}
+	files, err := l.Load("/init.rc")
+	if err != nil {
+		t.Fatalf("unexpected %s error", err)
+	}
+	var names []string
+	for _, f := range files {
+		names = append(names, f.Name)
+	}
+	want := []string{
+		"init.rc",
+		"init.goldfish.rc",
+		"etc/init/b.rc",
+		"etc/init/a.rc",
+		"misc.rc",
+		"vendor/x/init.rc",
+		"vendor/y/init.rc",
+	}
+	if strings.Join(names, " ") != strings.Join(want, " ") {
+		t.Fatalf("expected %q files, got %q", want, names)
+	}
+}
+
+func TestLoadCycle(t *testing.T) {
+	fsys := fstest.MapFS{
+		"a.rc": {Data: []byte("import b.rc\n")},
+		"b.rc": {Data: []byte("import dir/c.rc\n")},
+		"dir/c.rc": {Data: []byte("\nimport ../a.rc\n")},
+	}
+	l := &Loader{FS: fsys}
+	files, err := l.Load("a.rc")
+	e, ok := err.(*token.Error)
+	if !ok {
+		t.Fatalf("expected *token.Error, got %v", err)
+	}
+	if s := e.Error(); s != "dir/c.rc:2:1: import cycle: a.rc -> b.rc -> dir/c.rc -> a.rc" {
+		t.Fatalf("unexpected error %q", s)
+	}
+	if len(files) != 3 {
+		t.Fatalf("expected 3 files, got %d", len(files))
+	}
+}
+
+func TestLoadErrors(t *testing.T) {
+	fsys := fstest.MapFS{
+		"init.rc": {Data: []byte(strings.Join([]string{
+			"import missing.rc",
+			"import ${ro.unset}.rc",
+			"import nothing/*.rc",
+			"import bad.rc",
+			"import init.rc",
+		}, "\n"))},
+		"bad.rc": {Data: []byte("service\n")},
+	}
+	l := &Loader{FS: fsys, Mode: parser.AllErrors}
+	_, err := l.Load("init.rc")
+	errs, ok := err.(token.ErrorList)
+	if !ok {
+		t.Fatalf("expected token.ErrorList, got %v", err)
+	}
+	want := []string{
+		"bad.rc:1:1: service requires a name and a path",
+		"init.rc:1:1: import missing.rc: file does not exist",
+		"init.rc:2:1: import ${ro.unset}.rc: property \"ro.unset\" is not set",
+		"init.rc:3:1: import nothing/*.rc: no matching files",
+		"init.rc:5:1: import cycle: init.rc -> init.rc",
+	}
+	if len(errs) != len(want) {
+		t.Fatalf("expected %d errors, got %d: %v", len(want), len(errs), errs)
+	}
+	for i, s := range want {
+		if errs[i].Error() != s {
+			t.Errorf("expected %q error, got %q", s, errs[i])
+		}
+	}
+}
+
+func TestLoadMissing(t *testing.T) {
+	l := &Loader{FS: fstest.MapFS{}}
+	if _, err := l.Load("init.rc"); err == nil {
+		t.Fatal("expected error")
+	}
+}
+
+func TestLoadMultiple(t *testing.T) {
+	fsys := fstest.MapFS{
+		"init.rc": {Data: []byte("import etc/a.rc\n")},
+		"etc/a.rc": {Data: []byte("service a /bin/a\n")},
+		"etc/b.rc": {Data: []byte("service b /bin/b\n")},
+	}
+	l := &Loader{FS: fsys}
+	files, err := l.Load("/init.rc", "etc/a.rc", "etc/b.rc", "etc/../etc/b.rc")
+	if err != nil {
+		t.Fatalf("unexpected %s error", err)
+	}
+	var names []string
+	for _, f := range files {
+		names = append(names, f.Name)
+	}
+	if s := strings.Join(names, " "); s != "init.rc etc/a.rc etc/b.rc" {
+		t.Fatalf("unexpected %q files", s)
+	}
+	if err := Validate(files); err != nil {
+		t.Fatalf("unexpected %s error", err)
+	}
+}
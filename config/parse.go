@@ -1,24 +1,133 @@
 package config
 
 import (
+	"fmt"
 	"io"
 
+	"github.com/tie/x/config/lexer"
 	"github.com/tie/x/config/parser"
+	"github.com/tie/x/config/token"
 )
 
-func Parse(r io.RuneReader) (parser.Unit, error) {
-	return parser.Parse(r, syntax)
+// Parse parses a config file.  Filename is used in error messages and token positions.
+// Sections that fail to parse are omitted from the returned file.  In parser.AllErrors mode
+// the file contains all valid sections, and errors are returned as token.ErrorList.
+func Parse(filename string, r io.RuneReader, mode parser.Mode) (*File, error) {
+	return ParseOptions(filename, r, mode, lexer.Options{})
 }
 
-var syntax = parser.Syntax{
-	TopLevel: dummyCheck,
+// ParseOptions parses a config file written in the lexer dialect configured by opts, e.g.
+// with a different escape rune.  See Parse.
+func ParseOptions(filename string, r io.RuneReader, mode parser.Mode, opts lexer.Options) (*File, error) {
+	syn := Syntax
+	syn.Lexer = opts
+	unit, err := parser.Parse(filename, r, syn, mode)
+	return Build(filename, unit), err
+}
+
+// Syntax is the syntax of Android init language config files.
+var Syntax = parser.Syntax{
+	TopLevel: topLevelCheck,
 	Sections: map[string]parser.CheckFunc{
-		"on": dummyCheck,
-		"import": dummyCheck,
-		"service": dummyCheck,
+		"on": triggerCheck,
+		"import": importCheck,
+		"service": serviceCheck,
 	},
 }
 
-func dummyCheck(stmt parser.Statement) error {
+// topLevelCheck rejects statements outside of sections.
+func topLevelCheck(stmt parser.Statement) error {
+	return token.Errorf(stmt[0].Pos, token.UnknownSection, "unknown section %q", stmt.Directive())
+}
+
+func importCheck(stmt parser.Statement) error {
+	if stmt.Directive() != "import" {
+		// import has no body
+		return topLevelCheck(stmt)
+	}
+	args, err := stmt.Text()
+	if err != nil {
+		return err
+	}
+	if len(args) != 2 {
+		return fmt.Errorf("import requires exactly one path")
+	}
 	return nil
 }
+
+// Build converts sections checked with Syntax to typed values, e.g. sections of a unit
+// returned by parser.Document.Unit.
+func Build(filename string, unit parser.Unit) *File {
+	f := &File{
+		Name: filename,
+	}
+	if len(unit) == 0 {
+		return f
+	}
+	for _, section := range unit.Sections() {
+		header := section[0]
+		args := text(header)
+		switch header.Directive() {
+		case "service":
+			f.Services = append(f.Services, &Service{
+				Pos: header[0].Pos,
+				Name: args[1],
+				Path: args[2],
+				Args: args[3:],
+				Options: commands(section[1:]),
+				PathPos: header[2].Pos,
+				ArgPos: positions(header[3:]),
+			})
+		case "on":
+			conds, err := parseConditions(header)
+			if err != nil {
+				// it's a bug: header was not checked
+				panic(err)
+			}
+			f.Triggers = append(f.Triggers, &Trigger{
+				Pos: header[0].Pos,
+				Conditions: conds,
+				Commands: commands(section[1:]),
+			})
+		case "import":
+			f.Imports = append(f.Imports, &Import{
+				Pos: header[0].Pos,
+				Path: args[1],
+			})
+		}
+	}
+	return f
+}
+
+func commands(stmts []parser.Statement) []Command {
+	cmds := make([]Command, 0, len(stmts))
+	for _, stmt := range stmts {
+		args := text(stmt)
+		cmds = append(cmds, Command{
+			Pos: stmt[0].Pos,
+			Name: args[0],
+			Args: args[1:],
+			ArgPos: positions(stmt[1:]),
+		})
+	}
+	return cmds
+}
+
+// positions returns positions of the tokens.
+func positions(toks []token.Token) []token.Position {
+	pos := make([]token.Position, len(toks))
+	for i, tok := range toks {
+		pos[i] = tok.Pos
+	}
+	return pos
+}
+
+// text returns decoded values of statement that has already passed syntax checks.
+func text(stmt parser.Statement) []string {
+	args, err := stmt.Text()
+	if err != nil {
+		// it's a bug: statement was not checked
+		panic(err)
+	}
+	return args
+}
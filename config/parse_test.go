@@ -0,0 +1,170 @@
+package config
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/tie/x/config/lexer"
+	"github.com/tie/x/config/parser"
+	"github.com/tie/x/config/token"
+)
+
+func TestParse(t *testing.T) {
+	input := strings.Join([]string{
+		"import /etc/init/hw.rc",
+		"",
+		"on boot",
+		"    mkdir /data \"0755\"",
+		"",
+		"service logd /system/bin/logd \"-f x\"",
+		"    class core",
+		"    socket logd stream 0666 logd logd",
+	}, "\n")
+	f, err := Parse("init.rc", strings.NewReader(input), 0)
+	if err != nil {
+		t.Fatalf("unexpected %s error", err)
+	}
+	want := &File{
+		Name: "init.rc",
+		Imports: []*Import{
+			{
+				Pos: token.Position{Filename: "init.rc"},
+				Path: "/etc/init/hw.rc",
+			},
+		},
+		Triggers: []*Trigger{
+			{
+				Pos: token.Position{Filename: "init.rc", Offset: 24, Line: 2},
+				Conditions: []Condition{
+					{
+						Pos: token.Position{Filename: "init.rc", Offset: 27, Line: 2, Column: 3},
+						Event: "boot",
+					},
+				},
+				Commands: []Command{
+					{
+						Pos: token.Position{Filename: "init.rc", Offset: 36, Line: 3, Column: 4},
+						Name: "mkdir",
+						Args: []string{"/data", "0755"},
+						ArgPos: []token.Position{
+							token.Position{Filename: "init.rc", Offset: 42, Line: 3, Column: 10},
+							token.Position{Filename: "init.rc", Offset: 48, Line: 3, Column: 16},
+						},
+					},
+				},
+			},
+		},
+		Services: []*Service{
+			{
+				Pos: token.Position{Filename: "init.rc", Offset: 56, Line: 5},
+				Name: "logd",
+				Path: "/system/bin/logd",
+				Args: []string{"-f x"},
+				PathPos: token.Position{Filename: "init.rc", Offset: 69, Line: 5, Column: 13},
+				ArgPos: []token.Position{
+					token.Position{Filename: "init.rc", Offset: 86, Line: 5, Column: 30},
+				},
+				Options: []Command{
+					{
+						Pos: token.Position{Filename: "init.rc", Offset: 97, Line: 6, Column: 4},
+						Name: "class",
+						Args: []string{"core"},
+						ArgPos: []token.Position{
+							token.Position{Filename: "init.rc", Offset: 103, Line: 6, Column: 10},
+						},
+					},
+					{
+						Pos: token.Position{Filename: "init.rc", Offset: 112, Line: 7, Column: 4},
+						Name: "socket",
+						Args: []string{"logd", "stream", "0666", "logd", "logd"},
+						ArgPos: []token.Position{
+							token.Position{Filename: "init.rc", Offset: 119, Line: 7, Column: 11},
+							token.Position{Filename: "init.rc", Offset: 124, Line: 7, Column: 16},
+							token.Position{Filename: "init.rc", Offset: 131, Line: 7, Column: 23},
+							token.Position{Filename: "init.rc", Offset: 136, Line: 7, Column: 28},
+							token.Position{Filename: "init.rc", Offset: 141, Line: 7, Column: 33},
+						},
+					},
+				},
+			},
+		},
+	}
+	if !reflect.DeepEqual(f, want) {
+		t.Fatalf("expected\n%+v\ngot\n%+v", want, f)
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	input := strings.Join([]string{
+		"stray statement",
+		"service only-name",
+		"    class core",
+		"on",
+		"import",
+		"import a b",
+		"import ok.rc",
+		"    body",
+		"service ok /bin/ok",
+		"    user \\q",
+	}, "\n")
+	f, err := Parse("init.rc", strings.NewReader(input), parser.AllErrors)
+	errs, ok := err.(token.ErrorList)
+	if !ok {
+		t.Fatalf("expected token.ErrorList, got %v", err)
+	}
+	want := []struct {
+		Line int
+		Code token.ErrorCode
+	}{
+		{0, token.UnknownSection},
+		{1, token.InvalidStatement},
+		{3, token.InvalidStatement},
+		{4, token.InvalidStatement},
+		{5, token.InvalidStatement},
+		{7, token.UnknownSection},
+		{9, token.InvalidEscape},
+	}
+	if len(errs) != len(want) {
+		t.Fatalf("expected %d errors, got %d: %v", len(want), len(errs), errs)
+	}
+	for i, w := range want {
+		if errs[i].Pos.Line != w.Line || errs[i].Code != w.Code {
+			t.Errorf("expected %s error at line %d, got %s error: %s", w.Code, w.Line+1, errs[i].Code, errs[i])
+		}
+	}
+	if len(f.Services) != 1 || f.Services[0].Name != "ok" || len(f.Services[0].Options) != 0 {
+		t.Errorf("unexpected services %+v", f.Services)
+	}
+	if len(f.Imports) != 1 || f.Imports[0].Path != "ok.rc" {
+		t.Errorf("unexpected imports %+v", f.Imports)
+	}
+}
+
+func TestParseOptions(t *testing.T) {
+	input := "service a /bin/a ^\n    --flag=`x y` ^#\n    setenv A `^` x`\n"
+	f, err := ParseOptions("init.rc", strings.NewReader(input), 0, lexer.Options{Quotes: []rune{'`'}, Escape: '^'})
+	if err != nil {
+		t.Fatalf("unexpected %s error", err)
+	}
+	s := f.Services[0]
+	if strings.Join(s.Args, "|") != "--flag=x y|#" {
+		t.Fatalf("unexpected %q arguments", s.Args)
+	}
+	if strings.Join(s.Options[0].Args, "|") != "A|` x" {
+		t.Fatalf("unexpected %q setenv arguments", s.Options[0].Args)
+	}
+}
+
+func TestBuildDocument(t *testing.T) {
+	input := "import /a.rc\non boot\n    start a\n    frobnicate\nservice a /bin/a x\n    user\n    disabled\nservice\n    user root\n"
+	want, err := Parse("init.rc", strings.NewReader(input), parser.AllErrors)
+	d := parser.NewDocument("init.rc", []byte(input), Syntax)
+	unit, errs := d.Unit()
+	if !reflect.DeepEqual(error(errs), err) {
+		t.Fatalf("expected %v errors, got %v", err, errs)
+	}
+	if f := Build("init.rc", unit); !reflect.DeepEqual(f, want) {
+		t.Fatalf("expected %+v file, got %+v", want, f)
+	}
+}
@@ -0,0 +1,282 @@
+package parser
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+
+	"github.com/tie/x/config/token"
+)
+
+// Edit is a text edit that replaces bytes of the source in range [Start, End) with Text.
+type Edit struct {
+	Start, End int
+	Text string
+}
+
+// Document is a syntax tree of a source that is kept up to date with text edits.
+//
+// Since statements end at separators, an edit only affects the statements it touches, and
+// possibly the following ones if it changes line folding or quoting.  Update re-lexes the
+// source from the first affected statement until lexing is back in sync with the old nodes,
+// and reuses the rest of the nodes with their positions shifted.  Statements are re-checked
+// only if they are new or moved to another section.
+type Document struct {
+	filename string
+	syn Syntax
+	src []byte
+	bom bool
+	// nodes cover the source without gaps, the last node holds trivia at the end of file
+	nodes []*docNode
+}
+
+// docNode is a node of the document along with its state.
+type docNode struct {
+	*Node
+	// start and end are byte offsets of the node in the source
+	start, end int
+	// pos is the position of the node start, it is always at the start of a line
+	pos token.Position
+	// err is the syntax error of the node
+	err *token.Error
+	// checked is set if the statement was checked in the section with header directive
+	// section, checkErr is the check result
+	checked bool
+	section string
+	checkErr *token.Error
+}
+
+// NewDocument parses source of the named file.  The source is copied, so it may be modified
+// by the caller afterwards.
+func NewDocument(filename string, src []byte, syn Syntax) *Document {
+	d := &Document{
+		filename: filename,
+		syn: syn,
+		src: bytes.Clone(src),
+	}
+	d.nodes, _ = d.lex(0, token.Position{Filename: filename}, nil)
+	return d
+}
+
+// Source returns a copy of the current source.
+func (d *Document) Source() []byte {
+	return bytes.Clone(d.src)
+}
+
+// Update applies the edit to the source and updates the syntax tree.  Trees returned by
+// Tree before the update must not be used after it, since nodes are reused.
+func (d *Document) Update(e Edit) error {
+	if e.Start < 0 || e.Start > e.End || e.End > len(d.src) {
+		return fmt.Errorf("edit range [%d, %d) out of bounds [0, %d)", e.Start, e.End, len(d.src))
+	}
+	src := make([]byte, 0, len(d.src)+len(e.Text)-(e.End-e.Start))
+	src = append(src, d.src[:e.Start]...)
+	src = append(src, e.Text...)
+	src = append(src, d.src[e.End:]...)
+	delta := len(e.Text) - (e.End - e.Start)
+	lineDelta := bytes.Count([]byte(e.Text), []byte("\n")) - bytes.Count(d.src[e.Start:e.End], []byte("\n"))
+	// find the first affected node
+	i := len(d.nodes) - 1
+	for k, n := range d.nodes {
+		if n.end > e.Start {
+			i = k
+			break
+		}
+	}
+	// nodes without separator are continued by the following text
+	for i > 0 && !endsWithSep(d.nodes[i-1].Node) {
+		i--
+	}
+	// lexer would skip the mark as BOM
+	if bytes.HasPrefix(src[d.nodes[i].start:], []byte("\uFEFF")) {
+		i = 0
+	}
+	d.src = src
+	old := d.nodes
+	// sync reports whether a new node ending at offset end is in sync with the old nodes,
+	// and returns index of the old node ending there
+	j := i
+	sync := func(end int) (int, bool) {
+		for j < len(old) && (old[j].end < e.End || old[j].end+delta < end) {
+			j++
+		}
+		return j, j < len(old)-1 && old[j].end+delta == end
+	}
+	nodes, j := d.lex(old[i].start, old[i].pos, sync)
+	nodes = append(old[:i:i], nodes...)
+	if j >= 0 {
+		for _, n := range old[j+1:] {
+			n.shift(delta, lineDelta)
+			nodes = append(nodes, n)
+		}
+	}
+	d.nodes = nodes
+	return nil
+}
+
+// lex reads nodes from the source starting at offset from, which is at the position pos.
+// Lexing stops at the end of file or when sync reports that a node ending at some offset
+// is in sync with an old node.  Index of the old node is returned then, or -1 otherwise.
+func (d *Document) lex(from int, pos token.Position, sync func(end int) (int, bool)) ([]*docNode, int) {
+	p := NewParserBytesOptions(d.filename, d.src[from:], d.syn.Lexer)
+	var nodes []*docNode
+	start, line := from, pos.Line
+	for {
+		n, err := p.NextNode()
+		if from == 0 {
+			d.bom = p.lexer.BOM()
+		}
+		dn := &docNode{
+			Node: n,
+		}
+		if e, ok := err.(*token.Error); ok {
+			dn.err = e
+		}
+		// lexer positions are relative to the offset
+		dn.shift(from, pos.Line)
+		dn.start, dn.end = start, nodeEnd(n, start)
+		dn.pos = token.Position{Filename: d.filename, Offset: start, Line: line}
+		start, line = dn.end, endLine(n, line)
+		nodes = append(nodes, dn)
+		if err == io.EOF {
+			return nodes, -1
+		}
+		if sync != nil {
+			if j, ok := sync(dn.end); ok {
+				return nodes, j
+			}
+		}
+	}
+}
+
+// Tree returns the syntax tree of the document grouped into sections as by ParseTree.
+func (d *Document) Tree() *Tree {
+	t := &Tree{
+		Sections: [][]*Node{nil},
+		BOM: d.bom,
+	}
+	for _, n := range d.nodes[:len(d.nodes)-1] {
+		if stmt := n.Statement(); len(stmt) > 0 {
+			if _, ok := d.syn.Sections[stmt.Directive()]; ok {
+				t.Sections = append(t.Sections, nil)
+			}
+		}
+		t.Sections[len(t.Sections)-1] = append(t.Sections[len(t.Sections)-1], n.Node)
+	}
+	t.Trailing = d.nodes[len(d.nodes)-1].Leading
+	return t
+}
+
+// Errors returns syntax errors of the document, including errors reported by check
+// functions, sorted by position.  Statements are checked as by Parse.
+func (d *Document) Errors() token.ErrorList {
+	_, errs := d.Unit()
+	return errs
+}
+
+// Unit returns the statements of the document grouped into sections and the syntax errors
+// sorted by position, as returned by Parse in AllErrors mode.  Check results are kept between
+// updates, so only new and moved statements are checked again.
+func (d *Document) Unit() (Unit, token.ErrorList) {
+	var unit Unit
+	var section Section
+	var errs token.ErrorList
+	check, directive := d.syn.TopLevel, ""
+	// skip is set when the section header is broken
+	skip := false
+	for _, n := range d.nodes {
+		stmt := n.Statement()
+		header := false
+		if len(stmt) > 0 {
+			if sectionCheck, ok := d.syn.Sections[stmt.Directive()]; ok {
+				unit, section = emitSection(unit, section)
+				check, directive, header, skip = sectionCheck, stmt.Directive(), true, false
+			}
+		}
+		if n.err == nil && len(stmt) > 0 && (!n.checked || n.section != directive) {
+			n.checked, n.section, n.checkErr = true, directive, nil
+			if check != nil {
+				if err := check(stmt); err != nil {
+					e := *statementError(stmt, err).(*token.Error)
+					n.checkErr = &e
+				}
+			}
+		}
+		err := n.err
+		if err == nil {
+			err = n.checkErr
+		}
+		if err != nil {
+			errs.Add(err)
+			if header {
+				skip = true
+			}
+			continue
+		}
+		if len(stmt) > 0 && check != nil && !skip {
+			section = append(section, stmt)
+		}
+	}
+	if len(section) > 0 || len(unit) <= 0 {
+		unit = append(unit, section)
+	}
+	errs.Sort()
+	return unit, errs
+}
+
+// shift moves the node by delta bytes and lineDelta lines.
+func (n *docNode) shift(delta, lineDelta int) {
+	if delta == 0 && lineDelta == 0 {
+		return
+	}
+	n.start += delta
+	n.end += delta
+	n.pos.Offset += delta
+	n.pos.Line += lineDelta
+	for _, toks := range [][]token.Token{n.Leading, n.Tokens, n.Trailing} {
+		for k := range toks {
+			shiftPosition(&toks[k].Pos, delta, lineDelta)
+			shiftPosition(&toks[k].End, delta, lineDelta)
+		}
+	}
+	for _, err := range []**token.Error{&n.err, &n.checkErr} {
+		if *err != nil {
+			// errors may be in use, e.g. returned by Errors
+			e := **err
+			shiftPosition(&e.Pos, delta, lineDelta)
+			*err = &e
+		}
+	}
+}
+
+// shiftPosition moves the position at or after the start of a line.  Columns are not
+// changed, since edits before the line do not affect them.
+func shiftPosition(pos *token.Position, delta, lineDelta int) {
+	pos.Offset += delta
+	pos.Line += lineDelta
+}
+
+// endLine returns the line of the node end.  Empty nodes end at line of their start.
+func endLine(n *Node, line int) int {
+	for _, toks := range [][]token.Token{n.Trailing, n.Tokens, n.Leading} {
+		if len(toks) > 0 {
+			return toks[len(toks)-1].End.Line
+		}
+	}
+	return line
+}
+
+// nodeEnd returns the offset of the node end.  Empty nodes end at start.
+func nodeEnd(n *Node, start int) int {
+	for _, toks := range [][]token.Token{n.Trailing, n.Tokens, n.Leading} {
+		if len(toks) > 0 {
+			return toks[len(toks)-1].End.Offset
+		}
+	}
+	return start
+}
+
+func endsWithSep(n *Node) bool {
+	return len(n.Trailing) > 0 && n.Trailing[len(n.Trailing)-1].Typ == token.SepToken ||
+		len(n.Tokens) == 0 && len(n.Leading) > 0 && n.Leading[len(n.Leading)-1].Typ == token.SepToken
+}
@@ -0,0 +1,195 @@
+package parser
+
+import (
+	"bytes"
+	"fmt"
+	"math/rand"
+	"reflect"
+	"strings"
+	"testing"
+	"unicode/utf8"
+
+	"github.com/tie/x/config/token"
+)
+
+var documentSyntax = Syntax{
+	TopLevel: func(stmt Statement) error {
+		return fmt.Errorf("statement outside of section")
+	},
+	Sections: map[string]CheckFunc{
+		"on": func(stmt Statement) error {
+			if stmt.Directive() == "bad" {
+				return fmt.Errorf("bad statement")
+			}
+			return nil
+		},
+		"service": nil,
+	},
+}
+
+const documentSource = "# header\non boot\n    start a # c\n    bad\n\nservice a /bin/a \\\n    x\n    user \"root\"\n# eof"
+
+// checkDocument compares the document with a freshly parsed source.
+func checkDocument(t *testing.T, d *Document) {
+	t.Helper()
+	src := d.Source()
+	tree, _ := ParseTree("f", src, documentSyntax, AllErrors)
+	got := d.Tree()
+	if !bytes.Equal(got.Bytes(), src) {
+		t.Fatalf("expected %q source, got %q", src, got.Bytes())
+	}
+	if !reflect.DeepEqual(got, tree) {
+		t.Fatalf("%q: tree differs from parsed tree", src)
+	}
+	unit, err := Parse("f", bytes.NewReader(src), documentSyntax, AllErrors)
+	var want token.ErrorList
+	if err != nil {
+		want = err.(token.ErrorList)
+	}
+	gotUnit, errs := d.Unit()
+	if !reflect.DeepEqual(errs, want) {
+		t.Fatalf("%q: expected %q errors, got %q", src, want, errs)
+	}
+	if !reflect.DeepEqual(gotUnit, unit) {
+		t.Fatalf("%q: expected %q unit, got %q", src, unit, gotUnit)
+	}
+}
+
+func TestDocument(t *testing.T) {
+	cases := []struct {
+		Name string
+		Edit Edit
+		Output string
+	}{
+		{
+			Name: "InsertStatement",
+			Edit: Edit{Start: 17, End: 17, Text: "    mkdir /data\n"},
+			Output: "# header\non boot\n    mkdir /data\n    start a # c\n",
+		},
+		{
+			Name: "ReplaceWord",
+			Edit: Edit{Start: 27, End: 28, Text: "bb"},
+			Output: "    start bb # c\n",
+		},
+		{
+			Name: "RemoveLine",
+			Edit: Edit{Start: 33, End: 41, Text: ""},
+			Output: "    start a # c\n\nservice",
+		},
+		{
+			Name: "OpenQuote",
+			Edit: Edit{Start: 27, End: 27, Text: "\""},
+			Output: "    start \"a # c\n",
+		},
+		{
+			Name: "FoldLine",
+			Edit: Edit{Start: 32, End: 32, Text: "\\"},
+			Output: "    start a # c\\\n    bad\n",
+		},
+		{
+			Name: "UnfoldLine",
+			Edit: Edit{Start: 59, End: 60, Text: ""},
+			Output: "service a /bin/a \n    x\n",
+		},
+		{
+			Name: "JoinLines",
+			Edit: Edit{Start: 16, End: 17, Text: " "},
+			Output: "on boot     start a",
+		},
+		{
+			Name: "NewSection",
+			Edit: Edit{Start: 33, End: 33, Text: "service b /bin/b\n"},
+			Output: "service b /bin/b\n    bad\n",
+		},
+		{
+			Name: "AppendAtEOF",
+			Edit: Edit{Start: len(documentSource), End: len(documentSource), Text: "\non init\n"},
+			Output: "# eof\non init\n",
+		},
+		{
+			Name: "ReplaceAll",
+			Edit: Edit{Start: 0, End: len(documentSource), Text: "on boot\n"},
+			Output: "on boot\n",
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.Name, func(t *testing.T) {
+			d := NewDocument("f", []byte(documentSource), documentSyntax)
+			checkDocument(t, d)
+			if err := d.Update(c.Edit); err != nil {
+				t.Fatalf("unexpected %s error", err)
+			}
+			if !strings.Contains(string(d.Source()), c.Output) {
+				t.Fatalf("expected %q in %q", c.Output, d.Source())
+			}
+			checkDocument(t, d)
+		})
+	}
+}
+
+func TestDocumentReuse(t *testing.T) {
+	d := NewDocument("f", []byte(documentSource), documentSyntax)
+	before := d.Tree()
+	d.Errors()
+	if err := d.Update(Edit{Start: 27, End: 28, Text: "b\nc"}); err != nil {
+		t.Fatalf("unexpected %s error", err)
+	}
+	after := d.Tree()
+	checkDocument(t, d)
+	// header and service sections are reused
+	if before.Sections[1][0] != after.Sections[1][0] {
+		t.Errorf("expected header node to be reused")
+	}
+	if before.Sections[2][0] != after.Sections[2][0] || before.Sections[2][1] != after.Sections[2][1] {
+		t.Errorf("expected service nodes to be reused")
+	}
+	if before.Sections[1][1] == after.Sections[1][1] {
+		t.Errorf("expected edited node to be replaced")
+	}
+}
+
+func TestDocumentRandomEdits(t *testing.T) {
+	pieces := []string{"\n", "\r\n", "\\", "\"", "'", "#", " ", "x", "on boot\n", "bad\n", "service s /bin/s\n", "\uFEFF"}
+	r := rand.New(rand.NewSource(1))
+	d := NewDocument("f", []byte(documentSource), documentSyntax)
+	for i := 0; i < 1000; i++ {
+		src := d.Source()
+		start := r.Intn(len(src) + 1)
+		end := start + r.Intn(len(src)-start+1)%8
+		text := ""
+		for n := r.Intn(3); n > 0; n-- {
+			text += pieces[r.Intn(len(pieces))]
+		}
+		// invalid UTF-8 is not preserved by the lexer
+		if !utf8.ValidString(string(src[:start]) + text + string(src[end:])) {
+			continue
+		}
+		if err := d.Update(Edit{Start: start, End: end, Text: text}); err != nil {
+			t.Fatalf("unexpected %s error", err)
+		}
+		checkDocument(t, d)
+		if len(d.Source()) > 400 {
+			d = NewDocument("f", []byte(documentSource), documentSyntax)
+		}
+	}
+}
+
+func TestDocumentEditRange(t *testing.T) {
+	d := NewDocument("f", []byte("a\n"), documentSyntax)
+	for _, e := range []Edit{{Start: -1, End: 0}, {Start: 1, End: 0}, {Start: 0, End: 3}} {
+		if err := d.Update(e); err == nil {
+			t.Errorf("expected error for %v edit", e)
+		}
+	}
+}
+
+func TestDocumentSourceCopy(t *testing.T) {
+	src := []byte(documentSource)
+	d := NewDocument("f", src, documentSyntax)
+	copy(src, "xxxxxxxx")
+	copy(d.Source(), "yyyyyyyy")
+	if got := string(d.Tree().Bytes()); got != documentSource {
+		t.Fatalf("expected %q source, got %q", documentSource, got)
+	}
+	checkDocument(t, d)
+}
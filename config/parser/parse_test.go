@@ -0,0 +1,151 @@
+package parser
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/tie/x/config/lexer"
+	"github.com/tie/x/config/token"
+)
+
+func TestParseCheckError(t *testing.T) {
+	syn := Syntax{
+		TopLevel: func(stmt Statement) error {
+			if stmt.Directive() == "bad" {
+				return errors.New("bad statement")
+			}
+			return nil
+		},
+	}
+	_, err := Parse("test.rc", strings.NewReader("good\n  bad x\n"), syn, 0)
+	e, ok := err.(*token.Error)
+	if !ok {
+		t.Fatalf("expected *token.Error, got %v", err)
+	}
+	want := token.Error{
+		Pos: token.Position{Filename: "test.rc", Offset: 7, Line: 1, Column: 2},
+		Code: token.InvalidStatement,
+		Msg: "bad statement",
+	}
+	if *e != want {
+		t.Fatalf("expected %#v error, got %#v", want, *e)
+	}
+	if s := e.Error(); s != "test.rc:2:3: bad statement" {
+		t.Fatalf("unexpected error string %q", s)
+	}
+}
+
+func TestParseAllErrors(t *testing.T) {
+	syn := Syntax{
+		TopLevel: func(stmt Statement) error {
+			return errors.New("top-level statement")
+		},
+		Sections: map[string]CheckFunc{
+			"section": func(stmt Statement) error {
+				for _, tok := range stmt {
+					if tok.Val == "bad" {
+						return errors.New("bad token")
+					}
+				}
+				return nil
+			},
+		},
+	}
+	input := strings.Join([]string{
+		"top",
+		"section a",
+		"  x \"y",
+		"  bad",
+		"  z",
+		"section bad",
+		"  bad",
+		"  w",
+		"section b",
+		"  v",
+	}, "\n")
+	unit, err := Parse("", strings.NewReader(input), syn, AllErrors)
+	errs, ok := err.(token.ErrorList)
+	if !ok {
+		t.Fatalf("expected token.ErrorList, got %v", err)
+	}
+	want := []struct {
+		Line int
+		Code token.ErrorCode
+	}{
+		{0, token.InvalidStatement},
+		{2, token.UnterminatedQuote},
+		{3, token.InvalidStatement},
+		{5, token.InvalidStatement},
+		{6, token.InvalidStatement},
+	}
+	if len(errs) != len(want) {
+		t.Fatalf("expected %d errors, got %d: %v", len(want), len(errs), errs)
+	}
+	for i, w := range want {
+		if errs[i].Pos.Line != w.Line || errs[i].Code != w.Code {
+			t.Errorf("expected %s error at line %d, got %s", w.Code, w.Line+1, errs[i])
+		}
+	}
+	var dirs [][]string
+	for _, section := range unit.Sections() {
+		var ss []string
+		for _, stmt := range section {
+			ss = append(ss, stmt[len(stmt)-1].Val)
+		}
+		dirs = append(dirs, ss)
+	}
+	if len(unit.TopLevel()) != 0 || len(dirs) != 2 ||
+		strings.Join(dirs[0], " ") != "a z" || strings.Join(dirs[1], " ") != "b v" {
+		t.Fatalf("unexpected unit %v", unit)
+	}
+}
+
+func TestParseDialect(t *testing.T) {
+	syn := Syntax{
+		Sections: map[string]CheckFunc{
+			"section": func(stmt Statement) error {
+				_, err := stmt.Text()
+				return err
+			},
+		},
+		Lexer: lexer.Options{
+			Comments: []rune{';'},
+			Quotes: []rune{'`'},
+			LiteralQuotes: []rune{},
+			Escape: '^',
+		},
+	}
+	input := strings.Join([]string{
+		"section a ^",
+		"  b # ; comment",
+		"  c:\\dir `x^`^^ y` 'z'",
+		"  ^; ^n^x41^u{42} ^",
+		"\tcontinued",
+	}, "\r\n")
+	unit, err := Parse("", strings.NewReader(input), syn, 0)
+	if err != nil {
+		t.Fatalf("unexpected %s error", err)
+	}
+	var stmts []string
+	for _, stmt := range unit.Sections()[0] {
+		args, err := stmt.Text()
+		if err != nil {
+			t.Fatalf("unexpected %s error", err)
+		}
+		stmts = append(stmts, strings.Join(args, "|"))
+	}
+	want := []string{
+		"section|a|b|#",
+		"c:\\dir|x`^ y|'z'",
+		";|\nAB|continued",
+	}
+	if strings.Join(stmts, "\n") != strings.Join(want, "\n") {
+		t.Fatalf("expected statements\n%q\ngot\n%q", want, stmts)
+	}
+
+	_, err = Parse("", strings.NewReader("section a^q"), syn, 0)
+	if e, ok := err.(*token.Error); !ok || e.Code != token.InvalidEscape || e.Msg != "unknown escape sequence ^q" {
+		t.Fatalf("expected unknown escape error, got %v", err)
+	}
+}
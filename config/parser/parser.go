@@ -28,7 +28,21 @@ func (s Statement) Directive() string {
 	return s[0].Val
 }
 
+// Text returns decoded values of statement tokens.  See token.Unquote.
+func (s Statement) Text() ([]string, error) {
+	vals := make([]string, len(s))
+	for i, tok := range s {
+		val, err := tok.Text()
+		if err != nil {
+			return nil, err
+		}
+		vals[i] = val
+	}
+	return vals, nil
+}
+
 // CheckFunc checks syntax of a statement and reports errors.
+// Errors other than *token.Error are reported at the statement position with token.InvalidStatement code.
 type CheckFunc func(stmt Statement) error
 
 // Syntax defines rules for checking syntax of sections.
@@ -38,39 +52,80 @@ type Syntax struct {
 	TopLevel CheckFunc
 	// Sections maps section header directive keyword to the section syntax checker.
 	Sections map[string]CheckFunc
+	// Lexer configures the lexer dialect, e.g. comment and escape runes.  The zero value is
+	// the default dialect.  SkipTrivia is ignored.
+	Lexer lexer.Options
 }
 
+// Mode controls parser behavior.
+type Mode uint
+
+const (
+	// AllErrors makes Parse continue after a failing statement and report all errors.
+	AllErrors Mode = 1 << iota
+)
+
 type Parser struct {
 	lexer *lexer.Lexer
 }
 
-func NewParser(r io.RuneReader) *Parser {
+// NewParser returns a parser reading from r.  Filename is recorded in token positions.
+func NewParser(filename string, r io.RuneReader) *Parser {
+	return NewParserOptions(filename, r, lexer.Options{})
+}
+
+// NewParserOptions returns a parser reading from r with the lexer configured with opts.
+func NewParserOptions(filename string, r io.RuneReader, opts lexer.Options) *Parser {
+	opts.SkipTrivia = false
+	return &Parser{
+		lexer: lexer.NewLexerOptions(filename, r, opts),
+	}
+}
+
+// NewParserBytes returns a parser reading from src.  Source of tokens is kept as by
+// lexer.NewLexerBytes.
+func NewParserBytes(filename string, src []byte) *Parser {
+	return NewParserBytesOptions(filename, src, lexer.Options{})
+}
+
+// NewParserBytesOptions returns a parser reading from src with the lexer configured with opts.
+func NewParserBytesOptions(filename string, src []byte, opts lexer.Options) *Parser {
+	opts.SkipTrivia = false
 	return &Parser{
-		lexer: lexer.NewLexer(r),
+		lexer: lexer.NewLexerBytesOptions(filename, src, opts),
 	}
 }
 
+// NextStatement returns the next non-empty statement.  It returns io.EOF at the end of input.
+// On syntax error the rest of the statement is still consumed, so that parsing may continue
+// with the next statement; the first syntax error is returned along with the statement.
 func (p *Parser) NextStatement() (Statement, error) {
 	stmt := Statement{}
+	var serr error
 	for {
 		tok, err := p.lexer.NextToken()
 		if err != nil {
-			// suppress eof if statement is not empty
-			if err == io.EOF && len(stmt) > 0 {
-				err = nil
+			if _, ok := err.(*token.Error); !ok {
+				// suppress eof if statement is not empty
+				if err == io.EOF && (len(stmt) > 0 || serr != nil) {
+					err = serr
+				}
+				return stmt, err
+			}
+			if serr == nil {
+				serr = err
 			}
-			return stmt, err
 		}
 		switch tok.Typ {
 		case token.SepToken:
 			// skip empty statements
-			if len(stmt) <= 0 {
+			if len(stmt) <= 0 && serr == nil {
 				continue
 			}
-			return stmt, nil
+			return stmt, serr
 		case token.TextToken:
 			// line folding
-			if tok.Val == "\\\n" {
+			if tok.Folding() {
 				continue
 			}
 			stmt = append(stmt, tok)
@@ -78,36 +133,81 @@ func (p *Parser) NextStatement() (Statement, error) {
 	}
 }
 
-func Parse(r io.RuneReader, syn Syntax) (unit Unit, err error) {
+// Parse reads all statements, checks their syntax and groups them into sections.
+// Syntax errors are returned as *token.Error.  In AllErrors mode statements that fail
+// are skipped, and all errors are returned as token.ErrorList sorted by position
+// along with the rest of the unit.  Sections with failing headers are skipped as
+// a whole, though statements in their bodies are still checked.
+func Parse(filename string, r io.RuneReader, syn Syntax, mode Mode) (unit Unit, err error) {
 	var section Section
-	p := NewParser(r)
+	var errs token.ErrorList
+	p := NewParserOptions(filename, r, syn.Lexer)
 	check := syn.TopLevel
+	// skip is set when the section header is broken
+	skip := false
 	for {
 		stmt, err := p.NextStatement()
-		if err != nil {
+		if _, ok := err.(*token.Error); !ok && err != nil {
 			if err == io.EOF {
 				err = nil
 				// don't forget to emit on eof
 				if len(section) > 0 || len(unit) <= 0 {
 					unit = append(unit, section)
 				}
+				if mode&AllErrors != 0 {
+					errs.Sort()
+					err = errs.Err()
+				}
 			}
 			return unit, err
 		}
-		dir := stmt.Directive()
-		if nextSectionCheck, ok := syn.Sections[dir]; ok {
-			// emit top-level or non-empty sections
-			if len(section) > 0 || len(unit) <= 0 {
-				unit = append(unit, section)
-				section = Section{}
+		header := false
+		if len(stmt) > 0 {
+			if nextSectionCheck, ok := syn.Sections[stmt.Directive()]; ok {
+				unit, section = emitSection(unit, section)
+				check, header, skip = nextSectionCheck, true, false
 			}
-			check = nextSectionCheck
 		}
-		if check != nil {
-			if err := check(stmt); err != nil {
+		if err == nil && check != nil {
+			err = check(stmt)
+			if err != nil {
+				err = statementError(stmt, err)
+			}
+		}
+		if err != nil {
+			if mode&AllErrors == 0 {
 				return unit, err
 			}
+			errs.Add(err)
+			if header {
+				skip = true
+			}
+			continue
+		}
+		if check != nil && !skip {
 			section = append(section, stmt)
 		}
 	}
 }
+
+// emitSection appends the section to the unit and starts a new one.  Only top-level
+// or non-empty sections are emitted.
+func emitSection(unit Unit, section Section) (Unit, Section) {
+	if len(section) > 0 || len(unit) <= 0 {
+		unit = append(unit, section)
+		section = Section{}
+	}
+	return unit, section
+}
+
+// statementError converts error returned by CheckFunc to *token.Error.
+func statementError(stmt Statement, err error) error {
+	if _, ok := err.(*token.Error); ok {
+		return err
+	}
+	return &token.Error{
+		Pos: stmt[0].Pos,
+		Code: token.InvalidStatement,
+		Msg: err.Error(),
+	}
+}
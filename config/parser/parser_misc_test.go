@@ -6,6 +6,7 @@ import (
 
 	"github.com/tie/x/config/internal/testingh"
 	"github.com/tie/x/config/internal/tokenh"
+	"github.com/tie/x/config/token"
 )
 
 func TestParserMisc(t *testing.T) {
@@ -52,6 +53,43 @@ func TestParserMisc(t *testing.T) {
 				expectEOF,
 			},
 		},
+		{
+			Name: "LexerError",
+			Input: []testingh.ReadRune{
+				// "a \"b"
+				{Rune: 'a', Size: 1},
+				{Rune: ' ', Size: 1},
+				{Rune: '"', Size: 1},
+				{Rune: 'b', Size: 1},
+				{Error: io.EOF},
+			},
+			Passes: []ParserTestPass{
+				expectError(tokenh.Error(token.UnterminatedQuote, "1:3(+2)")),
+			},
+		},
+		// statement is consumed up to the end of line on error
+		{
+			Name: "LexerErrorRecovery",
+			Input: []testingh.ReadRune{
+				// "\"a b\nc"
+				{Rune: '"', Size: 1},
+				{Rune: 'a', Size: 1},
+				{Rune: ' ', Size: 1},
+				{Rune: 'b', Size: 1},
+				{Rune: '\n', Size: 1},
+				{Rune: 'c', Size: 1},
+				{Error: io.EOF},
+			},
+			Passes: []ParserTestPass{
+				expectError(tokenh.Error(token.UnterminatedQuote, "1:1(+0)")),
+				expectStatements([]Statement{
+					{
+						tokenh.Text("c", "2:1(+5)", "2:2(+6)"),
+					},
+				}),
+				expectEOF,
+			},
+		},
 		// TODO: add more parser tests
 	})
 }
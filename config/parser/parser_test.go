@@ -5,11 +5,13 @@ import (
 	"testing"
 
 	"github.com/tie/x/config/internal/testingh"
+	"github.com/tie/x/config/token"
 )
 
 type (
 	ParserTest struct {
 		Name string
+		Filename string
 		Input []testingh.ReadRune
 		Passes []ParserTestPass
 	}
@@ -19,7 +21,7 @@ type (
 func RunParserTests(t *testing.T, cases []ParserTest) {
 	for _, c := range cases {
 		r := testingh.NewRuneReader(c.Input)
-		p, passes := NewParser(r), c.Passes
+		p, passes := NewParser(c.Filename, r), c.Passes
 		t.Run(c.Name, func(t *testing.T) {
 			for _, pass := range passes {
 				pass(t, p)
@@ -63,3 +65,17 @@ func expectEOF(t *testing.T, p *Parser) {
 		}
 	}
 }
+
+// expectError expects a syntax error.  Error messages are not compared.
+func expectError(want *token.Error) ParserTestPass {
+	return func(t *testing.T, p *Parser) {
+		stmt, err := p.NextStatement()
+		e, ok := err.(*token.Error)
+		if !ok {
+			t.Fatalf("expected %s error at %s, got %s statement with %v error", want.Code, want.Pos, stmt, err)
+		}
+		if e.Code != want.Code || e.Pos != want.Pos {
+			t.Fatalf("expected %s error at %s, got %s error at %s", want.Code, want.Pos, e.Code, e.Pos)
+		}
+	}
+}
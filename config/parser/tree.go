@@ -0,0 +1,208 @@
+package parser
+
+import (
+	"bytes"
+	"io"
+
+	"github.com/tie/x/config/token"
+)
+
+// Node is a statement along with the surrounding trivia, i.e. spaces, comments and separators.
+// Nodes are lossless: sources of all tokens concatenated in order reproduce the source.
+type Node struct {
+	// Leading are blank and comment lines preceding the statement, separators included.
+	Leading []token.Token
+	// Tokens are tokens of the statement up to the last text token, spaces and line folding included.
+	Tokens []token.Token
+	// Trailing are spaces and comment following the statement, and the separator if any.
+	Trailing []token.Token
+}
+
+// Statement returns text tokens of the node without line folding, as returned by NextStatement.
+func (n *Node) Statement() Statement {
+	stmt := Statement{}
+	for _, tok := range n.Tokens {
+		if tok.Typ == token.TextToken && !tok.Folding() {
+			stmt = append(stmt, tok)
+		}
+	}
+	return stmt
+}
+
+// Comments returns leading and trailing comment tokens of the node.
+func (n *Node) Comments() []token.Token {
+	var comments []token.Token
+	for _, toks := range [][]token.Token{n.Leading, n.Tokens, n.Trailing} {
+		for _, tok := range toks {
+			if tok.Typ == token.CommentToken {
+				comments = append(comments, tok)
+			}
+		}
+	}
+	return comments
+}
+
+// WriteTo writes source of the node.
+func (n *Node) WriteTo(w io.Writer) (int64, error) {
+	var written int64
+	for _, toks := range [][]token.Token{n.Leading, n.Tokens, n.Trailing} {
+		for _, tok := range toks {
+			m, err := io.WriteString(w, tok.Source())
+			written += int64(m)
+			if err != nil {
+				return written, err
+			}
+		}
+	}
+	return written, nil
+}
+
+// Tree is a lossless concrete syntax tree of a config file.  Invalid UTF-8 sequences are
+// replaced with U+FFFD in token values, but kept in their sources, so that the tree reproduces
+// any source byte for byte.
+type Tree struct {
+	// Sections are sequences of nodes.  Like in Unit, the first section is always a top-level
+	// section, and other sections start with a header node.
+	Sections [][]*Node
+	// Trailing are blank and comment lines at the end of file not followed by any statement.
+	Trailing []token.Token
+	// BOM is set if the source starts with a byte order mark.  The mark is not part of any token.
+	BOM bool
+}
+
+// WriteTo writes source of the tree.
+func (t *Tree) WriteTo(w io.Writer) (int64, error) {
+	var written int64
+	if t.BOM {
+		m, err := io.WriteString(w, "\uFEFF")
+		written += int64(m)
+		if err != nil {
+			return written, err
+		}
+	}
+	for _, section := range t.Sections {
+		for _, n := range section {
+			m, err := n.WriteTo(w)
+			written += m
+			if err != nil {
+				return written, err
+			}
+		}
+	}
+	for _, tok := range t.Trailing {
+		m, err := io.WriteString(w, tok.Source())
+		written += int64(m)
+		if err != nil {
+			return written, err
+		}
+	}
+	return written, nil
+}
+
+// Bytes returns source of the tree.
+func (t *Tree) Bytes() []byte {
+	var b bytes.Buffer
+	t.WriteTo(&b)
+	return b.Bytes()
+}
+
+// NextNode returns the next statement with its trivia.  At the end of input it returns io.EOF
+// along with a node without statement that holds the remaining trivia in Leading.
+// Syntax errors are handled as in NextStatement.
+func (p *Parser) NextNode() (*Node, error) {
+	n := &Node{}
+	// line is the current line without separator
+	var line []token.Token
+	var serr error
+	for {
+		tok, err := p.lexer.NextToken()
+		if err != nil {
+			if _, ok := err.(*token.Error); !ok {
+				if err != io.EOF {
+					return n, err
+				}
+				if !hasText(line) {
+					n.Leading = append(n.Leading, line...)
+					if serr != nil {
+						return n, serr
+					}
+					return n, io.EOF
+				}
+				n.Tokens, n.Trailing = splitTrailing(line)
+				return n, serr
+			}
+			if serr == nil {
+				serr = err
+			}
+		}
+		if tok.Typ != token.SepToken {
+			line = append(line, tok)
+			continue
+		}
+		if !hasText(line) {
+			n.Leading = append(n.Leading, line...)
+			n.Leading = append(n.Leading, tok)
+			line = nil
+			if serr != nil {
+				return n, serr
+			}
+			continue
+		}
+		n.Tokens, n.Trailing = splitTrailing(line)
+		n.Trailing = append(n.Trailing, tok)
+		return n, serr
+	}
+}
+
+// ParseTree parses all statements of the source with trivia and groups them into sections.
+// Check functions of the syntax are not called, only section keywords are used.  Syntax errors
+// are returned as *token.Error.  In AllErrors mode parsing continues after errors, and all
+// errors are returned as token.ErrorList.  Nodes with errors are kept in the tree, so that it
+// is always lossless.  The source is copied, see lexer.NewLexerBytes.
+func ParseTree(filename string, src []byte, syn Syntax, mode Mode) (*Tree, error) {
+	var errs token.ErrorList
+	p := NewParserBytesOptions(filename, src, syn.Lexer)
+	t := &Tree{
+		Sections: [][]*Node{nil},
+	}
+	for {
+		n, err := p.NextNode()
+		t.BOM = p.lexer.BOM()
+		if err == io.EOF {
+			t.Trailing = n.Leading
+			errs.Sort()
+			return t, errs.Err()
+		}
+		if err != nil {
+			if _, ok := err.(*token.Error); !ok || mode&AllErrors == 0 {
+				return t, err
+			}
+			errs.Add(err)
+		}
+		// nodes may have no statement, e.g. on error in a comment line
+		if stmt := n.Statement(); len(stmt) > 0 {
+			if _, ok := syn.Sections[stmt.Directive()]; ok {
+				t.Sections = append(t.Sections, nil)
+			}
+		}
+		t.Sections[len(t.Sections)-1] = append(t.Sections[len(t.Sections)-1], n)
+	}
+}
+
+func hasText(toks []token.Token) bool {
+	for _, tok := range toks {
+		if tok.Typ == token.TextToken {
+			return true
+		}
+	}
+	return false
+}
+
+// splitTrailing splits line at the last text token.
+func splitTrailing(line []token.Token) (toks, trailing []token.Token) {
+	i := len(line)
+	for i > 0 && line[i-1].Typ != token.TextToken {
+		i--
+	}
+	return line[:i], line[i:]
+}
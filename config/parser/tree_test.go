@@ -0,0 +1,123 @@
+package parser
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/tie/x/config/token"
+)
+
+var treeSyntax = Syntax{
+	Sections: map[string]CheckFunc{
+		"on": nil,
+		"service": nil,
+	},
+}
+
+func TestParseTreeRoundTrip(t *testing.T) {
+	inputs := []string{
+		"",
+		"\n",
+		"a",
+		"a\n",
+		"  # comment only",
+		"# header comment\n\non boot # trailing\n    # leading\n    start a  \n\n\n# eof comment\n",
+		"service a /bin/a \\\n    x \\\n    y\n\tuser\t\"r o o t\"   # c\n",
+		"top level\n\t\n on x\n\t'a b'\"c\"\\ d\n  \n",
+		"a \"unterminated\nb \\",
+		"\uFEFFon boot\r\n\tstart a \\\r\n  b\r\n\r",
+		"on \xff\n\tstart \"a\xfe\xfd\" # \xc3\n\xe2\x82",
+	}
+	for _, input := range inputs {
+		tree, _ := ParseTree("", []byte(input), treeSyntax, AllErrors)
+		if out := string(tree.Bytes()); out != input {
+			t.Errorf("expected %q, got %q", input, out)
+		}
+	}
+}
+
+func FuzzParseTreeRoundTrip(f *testing.F) {
+	f.Add([]byte("on boot\n    start a # c\n"))
+	f.Add([]byte("service a /bin/a \\\r\n  \"x\xff\" 'y\n"))
+	f.Add([]byte("\uFEFF\xef\xbb"))
+	f.Fuzz(func(t *testing.T, src []byte) {
+		tree, _ := ParseTree("", src, treeSyntax, AllErrors)
+		if out := tree.Bytes(); string(out) != string(src) {
+			t.Fatalf("expected %q, got %q", src, out)
+		}
+	})
+}
+
+func TestParseTreeAttachment(t *testing.T) {
+	input := strings.Join([]string{
+		"# file comment",
+		"",
+		"on boot # boot trigger",
+		"    # make dirs",
+		"    mkdir /data",
+		"",
+		"    start a   ",
+		"# service a",
+		"service a /bin/a",
+		"",
+		"# dangling",
+		"",
+	}, "\n")
+	tree, err := ParseTree("", []byte(input), treeSyntax, 0)
+	if err != nil {
+		t.Fatalf("unexpected %s error", err)
+	}
+	if len(tree.Sections) != 3 || len(tree.Sections[0]) != 0 {
+		t.Fatalf("unexpected sections %v", tree.Sections)
+	}
+	cases := []struct {
+		Node *Node
+		Leading, Statement, Trailing string
+	}{
+		{tree.Sections[1][0], "# file comment\n\n", "on boot", " # boot trigger\n"},
+		{tree.Sections[1][1], "    # make dirs\n", "mkdir /data", "\n"},
+		{tree.Sections[1][2], "\n", "start a", "   \n"},
+		{tree.Sections[2][0], "# service a\n", "service a /bin/a", "\n"},
+	}
+	for _, c := range cases {
+		if s := join(c.Node.Leading); s != c.Leading {
+			t.Errorf("expected %q leading trivia, got %q", c.Leading, s)
+		}
+		if s := strings.TrimSpace(join(c.Node.Tokens)); s != c.Statement {
+			t.Errorf("expected %q statement, got %q", c.Statement, s)
+		}
+		if s := join(c.Node.Trailing); s != c.Trailing {
+			t.Errorf("expected %q trailing trivia, got %q", c.Trailing, s)
+		}
+	}
+	if s := join(tree.Trailing); s != "\n# dangling\n" {
+		t.Errorf("unexpected trailing trivia %q", s)
+	}
+	comments := tree.Sections[1][0].Comments()
+	if len(comments) != 2 || comments[0].Val != "# file comment" || comments[1].Val != "# boot trigger" {
+		t.Errorf("unexpected comments %v", comments)
+	}
+}
+
+func TestParseTreeErrors(t *testing.T) {
+	input := "on \"boot\nservice a /bin/a \\q\n  user \"x\n"
+	tree, err := ParseTree("", []byte(input), treeSyntax, AllErrors)
+	errs, ok := err.(token.ErrorList)
+	if !ok || len(errs) != 2 {
+		t.Fatalf("expected 2 errors, got %v", err)
+	}
+	if len(tree.Sections) != 3 {
+		t.Fatalf("expected 3 sections, got %d", len(tree.Sections))
+	}
+	if s := string(tree.Bytes()); s != input {
+		t.Fatalf("expected %q, got %q", input, s)
+	}
+}
+
+func join(toks []token.Token) string {
+	var b strings.Builder
+	for _, tok := range toks {
+		b.WriteString(tok.Val)
+	}
+	return b.String()
+}
@@ -0,0 +1,194 @@
+package config
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/tie/x/config/token"
+)
+
+// MaxExpandDepth is the maximum nesting depth of property references.
+const MaxExpandDepth = 8
+
+// Properties provides values of system properties.
+type Properties interface {
+	// Property returns the property value and whether the property is set.
+	Property(name string) (string, bool)
+}
+
+// PropertyMap is Properties backed by a map.
+type PropertyMap map[string]string
+
+func (m PropertyMap) Property(name string) (string, bool) {
+	val, ok := m[name]
+	return val, ok
+}
+
+// PropertyFunc is Properties backed by a lookup function.
+type PropertyFunc func(name string) (string, bool)
+
+func (f PropertyFunc) Property(name string) (string, bool) {
+	return f(name)
+}
+
+// Expand replaces property references in s with property values.
+//
+//	${name}            value of the property, it is an error if the property is not set
+//	${name:-default}   value of the property if it is set and not empty, default otherwise
+//	$$                 literal $
+//
+// Defaults may contain references themselves up to MaxExpandDepth levels, and are expanded
+// only when used.  Property values are inserted literally and are not expanded again.
+// Other $ characters are left as is.  Props may be nil, in which case no properties are set.
+func Expand(s string, props Properties) (string, error) {
+	if !strings.Contains(s, "$") {
+		return s, nil
+	}
+	x := &expander{
+		s: s,
+		props: props,
+	}
+	return x.text(0)
+}
+
+// expander is the state of a single Expand call.
+type expander struct {
+	s string
+	// i is the index of the next unread byte
+	i int
+	props Properties
+	// skip is set while parsing defaults that are not used, so that their references are not
+	// looked up
+	skip bool
+}
+
+// text expands s up to the end of string or, inside of a reference default, up to the
+// closing brace, which is not consumed.
+func (x *expander) text(depth int) (string, error) {
+	var b strings.Builder
+	for x.i < len(x.s) {
+		switch {
+		case x.s[x.i] == '}' && depth > 0:
+			return b.String(), nil
+		case strings.HasPrefix(x.s[x.i:], "$$"):
+			b.WriteByte('$')
+			x.i += 2
+		case strings.HasPrefix(x.s[x.i:], "${"):
+			x.i += 2
+			val, err := x.reference(depth + 1)
+			if err != nil {
+				return "", err
+			}
+			b.WriteString(val)
+		default:
+			b.WriteByte(x.s[x.i])
+			x.i++
+		}
+	}
+	if depth > 0 {
+		return "", fmt.Errorf("unterminated property reference")
+	}
+	return b.String(), nil
+}
+
+// reference expands a property reference following "${".
+func (x *expander) reference(depth int) (string, error) {
+	if depth > MaxExpandDepth {
+		return "", fmt.Errorf("property references nested deeper than %d levels", MaxExpandDepth)
+	}
+	start := x.i
+	for x.i < len(x.s) && x.s[x.i] != '}' && !strings.HasPrefix(x.s[x.i:], ":-") {
+		if strings.HasPrefix(x.s[x.i:], "${") {
+			return "", fmt.Errorf("nested property reference in name %q", x.s[start:x.i])
+		}
+		x.i++
+	}
+	if x.i == len(x.s) {
+		return "", fmt.Errorf("unterminated property reference")
+	}
+	name := x.s[start:x.i]
+	if name == "" {
+		return "", fmt.Errorf("empty property name")
+	}
+	var val string
+	var ok bool
+	if x.props != nil && !x.skip {
+		val, ok = x.props.Property(name)
+	}
+	if x.s[x.i] == '}' {
+		x.i++
+		if !ok && !x.skip {
+			return "", fmt.Errorf("property %q is not set", name)
+		}
+		return val, nil
+	}
+	// default
+	x.i += 2
+	skip := x.skip
+	x.skip = skip || val != ""
+	def, err := x.text(depth)
+	x.skip = skip
+	if err != nil {
+		return "", err
+	}
+	x.i++
+	if val != "" {
+		return val, nil
+	}
+	return def, nil
+}
+
+// Expand returns a copy of the command with property references expanded in arguments.
+// Errors are returned as *token.Error at the position of the failing argument.
+func (c Command) Expand(props Properties) (Command, error) {
+	args := make([]string, len(c.Args))
+	for i, arg := range c.Args {
+		val, err := Expand(arg, props)
+		if err != nil {
+			return c, token.Errorf(c.argPos(i), token.InvalidProperty, "%s: %s", c.Name, err)
+		}
+		args[i] = val
+	}
+	c.Args = args
+	return c, nil
+}
+
+// Expand expands property references in paths and arguments of services, service options and
+// trigger commands in place.  Services and commands with errors are left unchanged, and all
+// errors are returned as token.ErrorList.
+func (f *File) Expand(props Properties) error {
+	var errs token.ErrorList
+	expandCommands := func(cmds []Command) {
+		for i, cmd := range cmds {
+			c, err := cmd.Expand(props)
+			if err != nil {
+				errs.Add(err)
+				continue
+			}
+			cmds[i] = c
+		}
+	}
+	for _, s := range f.Services {
+		// the service is a command with the path as the first argument
+		c := Command{
+			Pos: s.Pos,
+			Name: "service " + s.Name,
+			Args: append([]string{s.Path}, s.Args...),
+		}
+		if s.ArgPos != nil {
+			c.ArgPos = append([]token.Position{s.PathPos}, s.ArgPos...)
+		}
+		c, err := c.Expand(props)
+		if err != nil {
+			errs.Add(err)
+		} else {
+			s.Path, s.Args = c.Args[0], c.Args[1:]
+		}
+		expandCommands(s.Options)
+	}
+	for _, t := range f.Triggers {
+		expandCommands(t.Commands)
+	}
+	errs.Sort()
+	return errs.Err()
+}
@@ -0,0 +1,141 @@
+package config
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/tie/x/config/token"
+)
+
+func TestExpand(t *testing.T) {
+	props := PropertyMap{
+		"ro.hardware": "goldfish",
+		"ro.empty": "",
+		"ro.ref": "${ro.hardware}",
+	}
+	cases := []struct {
+		Input string
+		Output string
+		Error string
+	}{
+		{Input: "", Output: ""},
+		{Input: "no refs", Output: "no refs"},
+		{Input: "/init.${ro.hardware}.rc", Output: "/init.goldfish.rc"},
+		{Input: "${ro.hardware}${ro.hardware}", Output: "goldfishgoldfish"},
+		{Input: "${ro.empty}", Output: ""},
+		{Input: "${ro.ref}", Output: "${ro.hardware}"},
+		{Input: "${ro.unset:-default}", Output: "default"},
+		{Input: "${ro.empty:-default}", Output: "default"},
+		{Input: "${ro.hardware:-default}", Output: "goldfish"},
+		{Input: "${ro.unset:-}", Output: ""},
+		{Input: "${ro.unset:-${ro.hardware}}", Output: "goldfish"},
+		{Input: "${ro.unset:-a${ro.unset2:-b}c}", Output: "abc"},
+		{Input: "${ro.hardware:-${ro.unset}}", Output: "goldfish"},
+		{Input: "$$", Output: "$"},
+		{Input: "$${ro.hardware}", Output: "${ro.hardware}"},
+		{Input: "$$$${ro.hardware}", Output: "$${ro.hardware}"},
+		{Input: "$ro.hardware $", Output: "$ro.hardware $"},
+		{Input: "${ro.unset:-$$}", Output: "$"},
+		{Input: "${ro.unset}", Error: `property "ro.unset" is not set`},
+		{Input: "${ro.unset:-${ro.unset2}}", Error: `property "ro.unset2" is not set`},
+		{Input: "${ro.hardware", Error: "unterminated property reference"},
+		{Input: "${ro.unset:-${ro.hardware}", Error: "unterminated property reference"},
+		{Input: "${}", Error: "empty property name"},
+		{Input: "${:-x}", Error: "empty property name"},
+		{Input: "${ro.${ro.hardware}}", Error: `nested property reference in name "ro."`},
+		{
+			Input: strings.Repeat("${a:-", MaxExpandDepth) + "x" + strings.Repeat("}", MaxExpandDepth),
+			Output: "x",
+		},
+		{
+			Input: strings.Repeat("${a:-", MaxExpandDepth+1) + "x" + strings.Repeat("}", MaxExpandDepth+1),
+			Error: "property references nested deeper than 8 levels",
+		},
+	}
+	for _, c := range cases {
+		out, err := Expand(c.Input, props)
+		if c.Error != "" {
+			if err == nil || err.Error() != c.Error {
+				t.Errorf("%q: expected %q error, got %v", c.Input, c.Error, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%q: unexpected %s error", c.Input, err)
+			continue
+		}
+		if out != c.Output {
+			t.Errorf("%q: expected %q, got %q", c.Input, c.Output, out)
+		}
+	}
+}
+
+func TestExpandLookup(t *testing.T) {
+	var names []string
+	props := PropertyFunc(func(name string) (string, bool) {
+		names = append(names, name)
+		return "", false
+	})
+	out, err := Expand("${a:-x}${b:-y}", props)
+	if err != nil {
+		t.Fatalf("unexpected %s error", err)
+	}
+	if out != "xy" {
+		t.Errorf("expected %q, got %q", "xy", out)
+	}
+	if strings.Join(names, " ") != "a b" {
+		t.Errorf("unexpected lookups %q", names)
+	}
+}
+
+func TestFileExpand(t *testing.T) {
+	input := strings.Join([]string{
+		"service a /bin/${ro.bin} ${ro.arg}",
+		"    setenv HW ${ro.hardware:-generic}",
+		"service b /bin/${ro.unset} ${ro.arg}",
+		"on boot",
+		"    mkdir /data/${ro.unset}",
+		"    write /proc/x $${literal}",
+	}, "\n")
+	f, err := Parse("init.rc", strings.NewReader(input), 0)
+	if err != nil {
+		t.Fatalf("unexpected %s error", err)
+	}
+	err = f.Expand(PropertyMap{"ro.arg": "-v", "ro.bin": "a"})
+	errs, ok := err.(token.ErrorList)
+	if !ok || len(errs) != 2 {
+		t.Fatalf("expected two errors, got %v", err)
+	}
+	want := []string{
+		`init.rc:3:11: service b: property "ro.unset" is not set`,
+		`init.rc:5:11: mkdir: property "ro.unset" is not set`,
+	}
+	for i, e := range errs {
+		if e.Code != token.InvalidProperty || e.Error() != want[i] {
+			t.Errorf("expected %q error, got %q", want[i], e)
+		}
+	}
+	if s := f.Services[0]; s.Path != "/bin/a" || strings.Join(s.Args, " ") != "-v" {
+		t.Errorf("unexpected service path %q and args %q", s.Path, s.Args)
+	}
+	if s := f.Services[1]; s.Path != "/bin/${ro.unset}" || strings.Join(s.Args, " ") != "${ro.arg}" {
+		t.Errorf("unexpected service path %q and args %q", s.Path, s.Args)
+	}
+	if args := strings.Join(f.Services[0].Options[0].Args, " "); args != "HW generic" {
+		t.Errorf("unexpected setenv args %q", args)
+	}
+	if args := strings.Join(f.Triggers[0].Commands[0].Args, " "); args != "/data/${ro.unset}" {
+		t.Errorf("unexpected mkdir args %q", args)
+	}
+	if args := strings.Join(f.Triggers[0].Commands[1].Args, " "); args != "/proc/x ${literal}" {
+		t.Errorf("unexpected write args %q", args)
+	}
+}
+
+func TestCommandExpandPosition(t *testing.T) {
+	pos := token.Position{Filename: "init.rc", Line: 1, Column: 4}
+	_, err := Command{Pos: pos, Name: "write", Args: []string{"/a", "${x}"}}.Expand(nil)
+	if e, ok := err.(*token.Error); !ok || e.Pos != pos {
+		t.Fatalf("expected error at the command position, got %v", err)
+	}
+}
@@ -0,0 +1,430 @@
+package config
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/tie/x/config/parser"
+	"github.com/tie/x/config/token"
+)
+
+// argCheck checks a single decoded argument.
+type argCheck func(arg string) error
+
+// optionSpec describes syntax of a service option.
+type optionSpec struct {
+	// Usage is a synopsis of the option, e.g. "user <username>".
+	Usage string
+	// Min and Max bound the number of arguments.  Max < 0 means no limit.
+	Min, Max int
+	// Args are checks of positional arguments.  The last check applies to the rest of arguments.
+	// Nil check accepts any argument.
+	Args []argCheck
+	// Check checks all arguments after other checks pass.  May be nil.
+	Check func(args []string) error
+}
+
+// serviceOptions is the schema of Android init service options.
+var serviceOptions = map[string]optionSpec{
+	"capabilities": {
+		Usage: "capabilities [ <capability> ]*",
+		Min: 0, Max: -1,
+		Args: []argCheck{argCapability},
+	},
+	"class": {
+		Usage: "class <name> [ <name> ]*",
+		Min: 1, Max: -1,
+	},
+	"console": {
+		Usage: "console [ <console> ]",
+		Min: 0, Max: 1,
+	},
+	"critical": {
+		Usage: "critical [ window=<minutes> ] [ target=<reboot target> ]",
+		Min: 0, Max: 2,
+		Args: []argCheck{argCritical},
+	},
+	"disabled": {
+		Usage: "disabled",
+	},
+	"enter_namespace": {
+		Usage: "enter_namespace <net|mnt> <path>",
+		Min: 2, Max: 2,
+		Args: []argCheck{argEnum("net", "mnt"), nil},
+	},
+	"file": {
+		Usage: "file <path> <r|w|rw>",
+		Min: 2, Max: 2,
+		Args: []argCheck{nil, argEnum("r", "w", "rw")},
+	},
+	"gentle_kill": {
+		Usage: "gentle_kill",
+	},
+	"group": {
+		Usage: "group <groupname> [ <groupname> ]*",
+		Min: 1, Max: -1,
+	},
+	"interface": {
+		Usage: "interface <interface name> <instance name>",
+		Min: 2, Max: 2,
+	},
+	"ioprio": {
+		Usage: "ioprio <rt|be|idle> <priority>",
+		Min: 2, Max: 2,
+		Args: []argCheck{argEnum("rt", "be", "idle"), argInt(0, 7)},
+	},
+	"keycodes": {
+		Usage: "keycodes <keycode> [ <keycode> ]*",
+		Min: 1, Max: -1,
+		Args: []argCheck{argInt(0, 0xffff)},
+	},
+	"memcg.limit_in_bytes": {
+		Usage: "memcg.limit_in_bytes <bytes>",
+		Min: 1, Max: 1,
+		Args: []argCheck{argInt(0, -1)},
+	},
+	"memcg.limit_percent": {
+		Usage: "memcg.limit_percent <percent>",
+		Min: 1, Max: 1,
+		Args: []argCheck{argInt(0, 100)},
+	},
+	"memcg.limit_property": {
+		Usage: "memcg.limit_property <property>",
+		Min: 1, Max: 1,
+	},
+	"memcg.soft_limit_in_bytes": {
+		Usage: "memcg.soft_limit_in_bytes <bytes>",
+		Min: 1, Max: 1,
+		Args: []argCheck{argInt(0, -1)},
+	},
+	"memcg.swappiness": {
+		Usage: "memcg.swappiness <swappiness>",
+		Min: 1, Max: 1,
+		Args: []argCheck{argInt(0, 200)},
+	},
+	"namespace": {
+		Usage: "namespace <pid|mnt>",
+		Min: 1, Max: 1,
+		Args: []argCheck{argEnum("pid", "mnt")},
+	},
+	"oneshot": {
+		Usage: "oneshot",
+	},
+	"onrestart": {
+		Usage: "onrestart <command> [ <argument> ]*",
+		Min: 1, Max: -1,
+	},
+	"oom_score_adjust": {
+		Usage: "oom_score_adjust <value>",
+		Min: 1, Max: 1,
+		Args: []argCheck{argInt(-1000, 1000)},
+	},
+	"override": {
+		Usage: "override",
+	},
+	"priority": {
+		Usage: "priority <priority>",
+		Min: 1, Max: 1,
+		Args: []argCheck{argInt(-20, 19)},
+	},
+	"reboot_on_failure": {
+		Usage: "reboot_on_failure <target>",
+		Min: 1, Max: 1,
+	},
+	"restart_period": {
+		Usage: "restart_period <seconds>",
+		Min: 1, Max: 1,
+		Args: []argCheck{argInt(5, -1)},
+	},
+	"rlimit": {
+		Usage: "rlimit <resource> <cur> <max>",
+		Min: 3, Max: 3,
+		Args: []argCheck{argRlimitResource, argRlimitValue},
+	},
+	"seclabel": {
+		Usage: "seclabel <seclabel>",
+		Min: 1, Max: 1,
+	},
+	"setenv": {
+		Usage: "setenv <name> <value>",
+		Min: 2, Max: 2,
+		Args: []argCheck{argEnvName, nil},
+	},
+	"shutdown": {
+		Usage: "shutdown critical",
+		Min: 1, Max: 1,
+		Args: []argCheck{argEnum("critical")},
+	},
+	"sigstop": {
+		Usage: "sigstop",
+	},
+	"socket": {
+		Usage: "socket <name> <type> <perm> [ <user> [ <group> [ <seclabel> ] ] ]",
+		Min: 3, Max: 6,
+		Args: []argCheck{nil, argSocketType, argOctal, nil},
+	},
+	"stdio_to_kmsg": {
+		Usage: "stdio_to_kmsg",
+	},
+	"task_profiles": {
+		Usage: "task_profiles <profile> [ <profile> ]*",
+		Min: 1, Max: -1,
+	},
+	"timeout_period": {
+		Usage: "timeout_period <seconds>",
+		Min: 1, Max: 1,
+		Args: []argCheck{argInt(1, -1)},
+	},
+	"updatable": {
+		Usage: "updatable",
+	},
+	"user": {
+		Usage: "user <username>",
+		Min: 1, Max: 1,
+	},
+	"writepid": {
+		Usage: "writepid <file> [ <file> ]*",
+		Min: 1, Max: -1,
+	},
+}
+
+func serviceCheck(stmt parser.Statement) error {
+	args, err := stmt.Text()
+	if err != nil {
+		return err
+	}
+	if stmt.Directive() == "service" {
+		if len(args) < 3 {
+			return fmt.Errorf("service requires a name and a path")
+		}
+		return nil
+	}
+	spec, ok := serviceOptions[args[0]]
+	if !ok {
+		if s := suggest(args[0], serviceOptions); s != "" {
+			return fmt.Errorf("unknown service option %q, did you mean %q?", args[0], s)
+		}
+		return fmt.Errorf("unknown service option %q", args[0])
+	}
+	return spec.check(stmt, args[1:])
+}
+
+// check checks arguments of the option statement.
+func (spec optionSpec) check(stmt parser.Statement, args []string) error {
+	i, err := spec.checkArgs(args)
+	switch {
+	case err == nil:
+		return nil
+	case i >= 0:
+		return token.Errorf(stmt[i+1].Pos, token.InvalidStatement, "%s: argument %d: %s", stmt.Directive(), i+1, err)
+	}
+	return fmt.Errorf("%s: %s, usage: %s", stmt.Directive(), err, spec.Usage)
+}
+
+// checkArgs checks the arguments.  It returns the index of the invalid argument, or -1 if
+// the error is not about a single argument.
+func (spec optionSpec) checkArgs(args []string) (int, error) {
+	if len(args) < spec.Min || spec.Max >= 0 && len(args) > spec.Max {
+		return -1, fmt.Errorf("%s", arity(spec.Min, spec.Max, len(args)))
+	}
+	for i, arg := range args {
+		if len(spec.Args) == 0 {
+			break
+		}
+		check := spec.Args[len(spec.Args)-1]
+		if i < len(spec.Args) {
+			check = spec.Args[i]
+		}
+		if check == nil {
+			continue
+		}
+		if err := check(arg); err != nil {
+			return i, err
+		}
+	}
+	if spec.Check != nil {
+		if err := spec.Check(args); err != nil {
+			return -1, err
+		}
+	}
+	return -1, nil
+}
+
+// arity formats an error message about wrong number of arguments.
+func arity(min, max, n int) string {
+	switch {
+	case min == max && min == 0:
+		return fmt.Sprintf("expected no arguments, got %d", n)
+	case min == max:
+		return fmt.Sprintf("expected %d arguments, got %d", min, n)
+	case max < 0:
+		return fmt.Sprintf("expected at least %d arguments, got %d", min, n)
+	case n < min:
+		return fmt.Sprintf("expected at least %d arguments, got %d", min, n)
+	}
+	return fmt.Sprintf("expected at most %d arguments, got %d", max, n)
+}
+
+// argInt returns a check for decimal integers in [min, max] range.  Max less than min means no upper limit.
+func argInt(min, max int) argCheck {
+	return func(arg string) error {
+		v, err := strconv.Atoi(arg)
+		if err != nil {
+			return fmt.Errorf("expected integer, got %q", arg)
+		}
+		if v < min || max >= min && v > max {
+			if max < min {
+				return fmt.Errorf("expected integer not less than %d, got %d", min, v)
+			}
+			return fmt.Errorf("expected integer in range [%d, %d], got %d", min, max, v)
+		}
+		return nil
+	}
+}
+
+// argEnum returns a check for one of the given values.
+func argEnum(vals ...string) argCheck {
+	return func(arg string) error {
+		for _, val := range vals {
+			if arg == val {
+				return nil
+			}
+		}
+		return fmt.Errorf("expected one of %s, got %q", strings.Join(vals, ", "), arg)
+	}
+}
+
+func argOctal(arg string) error {
+	if _, err := strconv.ParseUint(arg, 8, 32); err != nil {
+		return fmt.Errorf("expected octal permissions, got %q", arg)
+	}
+	return nil
+}
+
+func argEnvName(arg string) error {
+	if arg == "" || strings.ContainsAny(arg, "=\x00") {
+		return fmt.Errorf("invalid environment variable name %q", arg)
+	}
+	return nil
+}
+
+func argSocketType(arg string) error {
+	typ := arg
+	if i := strings.IndexByte(arg, '+'); i >= 0 {
+		typ = arg[:i]
+		for _, flag := range strings.Split(arg[i+1:], "+") {
+			if flag != "passcred" && flag != "listen" {
+				return fmt.Errorf("unknown socket flag %q", flag)
+			}
+		}
+	}
+	return argEnum("stream", "dgram", "seqpacket")(typ)
+}
+
+func argCritical(arg string) error {
+	i := strings.IndexByte(arg, '=')
+	if i < 0 {
+		return fmt.Errorf("expected window=<minutes> or target=<reboot target>, got %q", arg)
+	}
+	switch key, val := arg[:i], arg[i+1:]; key {
+	case "window":
+		return argInt(1, -1)(val)
+	case "target":
+		if val == "" {
+			return fmt.Errorf("empty reboot target")
+		}
+		return nil
+	}
+	return fmt.Errorf("expected window=<minutes> or target=<reboot target>, got %q", arg)
+}
+
+var capabilities = []string{
+	"CHOWN", "DAC_OVERRIDE", "DAC_READ_SEARCH", "FOWNER", "FSETID", "KILL", "SETGID", "SETUID",
+	"SETPCAP", "LINUX_IMMUTABLE", "NET_BIND_SERVICE", "NET_BROADCAST", "NET_ADMIN", "NET_RAW",
+	"IPC_LOCK", "IPC_OWNER", "SYS_MODULE", "SYS_RAWIO", "SYS_CHROOT", "SYS_PTRACE", "SYS_PACCT",
+	"SYS_ADMIN", "SYS_BOOT", "SYS_NICE", "SYS_RESOURCE", "SYS_TIME", "SYS_TTY_CONFIG", "MKNOD",
+	"LEASE", "AUDIT_WRITE", "AUDIT_CONTROL", "SETFCAP", "MAC_OVERRIDE", "MAC_ADMIN", "SYSLOG",
+	"WAKE_ALARM", "BLOCK_SUSPEND", "AUDIT_READ", "PERFMON", "BPF", "CHECKPOINT_RESTORE",
+}
+
+func argCapability(arg string) error {
+	return argEnum(capabilities...)(strings.TrimPrefix(arg, "CAP_"))
+}
+
+var rlimitResources = []string{
+	"cpu", "fsize", "data", "stack", "core", "rss", "nproc", "nofile", "memlock", "as",
+	"locks", "sigpending", "msgqueue", "nice", "rtprio", "rttime",
+}
+
+func argRlimitResource(arg string) error {
+	// either RLIMIT_ constant name, lower case name or resource number
+	name := strings.ToLower(strings.TrimPrefix(arg, "RLIMIT_"))
+	for i, res := range rlimitResources {
+		if name == res || name == strconv.Itoa(i) {
+			return nil
+		}
+	}
+	return fmt.Errorf("unknown rlimit resource %q", arg)
+}
+
+func argRlimitValue(arg string) error {
+	if arg == "unlimited" || arg == "-1" {
+		return nil
+	}
+	if _, err := strconv.ParseUint(arg, 10, 64); err != nil {
+		return fmt.Errorf("expected limit value or unlimited, got %q", arg)
+	}
+	return nil
+}
+
+// suggest returns a known option name close to the misspelled one, or empty string.
+func suggest(name string, opts map[string]optionSpec) string {
+	names := make([]string, 0, len(opts))
+	for opt := range opts {
+		names = append(names, opt)
+	}
+	sort.Strings(names)
+	best, bestDist := "", 3
+	for _, opt := range names {
+		d := distance(name, opt)
+		// misspelled prefixes of long option names, e.g. restat for restart_period
+		if len(name) >= 4 && len(opt) > len(name) {
+			if p := distance(name, opt[:len(name)]) + 1; p < d {
+				d = p
+			}
+		}
+		if d < bestDist {
+			best, bestDist = opt, d
+		}
+	}
+	return best
+}
+
+// distance returns Levenshtein distance between two strings.
+func distance(a, b string) int {
+	prev := make([]int, len(b)+1)
+	curr := make([]int, len(b)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		curr[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			curr[j] = prev[j-1] + cost
+			if v := prev[j] + 1; v < curr[j] {
+				curr[j] = v
+			}
+			if v := curr[j-1] + 1; v < curr[j] {
+				curr[j] = v
+			}
+		}
+		prev, curr = curr, prev
+	}
+	return prev[len(b)]
+}
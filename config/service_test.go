@@ -0,0 +1,94 @@
+package config
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/tie/x/config/parser"
+	"github.com/tie/x/config/token"
+)
+
+func TestServiceOptions(t *testing.T) {
+	cases := []struct {
+		Option string
+		// Error is a substring of the expected error message, empty if no error is expected.
+		Error string
+	}{
+		{Option: "class core main"},
+		{Option: "class", Error: "expected at least 1 arguments, got 0"},
+		{Option: "disabled"},
+		{Option: "disabled now", Error: "expected no arguments, got 1"},
+		{Option: "oneshot"},
+		{Option: "critical window=4 target=bootloader"},
+		{Option: "critical window=x", Error: "argument 1: expected integer"},
+		{Option: "critical reason=x", Error: "argument 1: expected window=<minutes>"},
+		{Option: "user system"},
+		{Option: "user system root", Error: "expected 1 arguments, got 2"},
+		{Option: "group system inet"},
+		{Option: "capabilities NET_ADMIN CAP_SYS_NICE"},
+		{Option: "capabilities NET_ADMIN SUPERPOWER", Error: "argument 2: expected one of"},
+		{Option: "restart_period 10"},
+		{Option: "restart_period 1", Error: "expected integer not less than 5, got 1"},
+		{Option: "restart_period soon", Error: "expected integer"},
+		{Option: "timeout_period 60"},
+		{Option: "setenv HOME /data"},
+		{Option: "setenv A=B x", Error: "invalid environment variable name"},
+		{Option: "socket adbd stream 660 system system"},
+		{Option: "socket adbd seqpacket+passcred 0660"},
+		{Option: "socket adbd raw 660", Error: "argument 2: expected one of stream, dgram, seqpacket"},
+		{Option: "socket adbd stream+bogus 660", Error: "unknown socket flag"},
+		{Option: "socket adbd stream 999", Error: "expected octal permissions"},
+		{Option: "socket adbd stream", Error: "expected at least 3 arguments, got 2"},
+		{Option: "socket a stream 660 u g l extra", Error: "expected at most 6 arguments, got 7"},
+		{Option: "file /dev/kmsg w"},
+		{Option: "file /dev/kmsg x", Error: "expected one of r, w, rw"},
+		{Option: "writepid /dev/cpuset/tasks"},
+		{Option: "priority -20"},
+		{Option: "priority 20", Error: "expected integer in range [-20, 19], got 20"},
+		{Option: "rlimit nofile 1024 unlimited"},
+		{Option: "rlimit RLIMIT_NOFILE 1024 4096"},
+		{Option: "rlimit 7 1024 4096"},
+		{Option: "rlimit files 1024 4096", Error: "unknown rlimit resource"},
+		{Option: "rlimit nofile lots 4096", Error: "argument 2: expected limit value"},
+		{Option: "namespace pid"},
+		{Option: "namespace net", Error: "expected one of pid, mnt"},
+		{Option: "ioprio rt 4"},
+		{Option: "ioprio rt 8", Error: "expected integer in range [0, 7]"},
+		{Option: "onrestart restart zygote"},
+		{Option: "onrestart", Error: "expected at least 1 arguments"},
+		{Option: "restat 10", Error: "unknown service option \"restat\", did you mean \"restart_period\"?"},
+		{Option: "usr root", Error: "did you mean \"user\"?"},
+		{Option: "frobnicate", Error: "unknown service option \"frobnicate\""},
+	}
+	for _, c := range cases {
+		c := c
+		t.Run(c.Option, func(t *testing.T) {
+			input := "service svc /bin/svc\n    " + c.Option + "\n"
+			_, err := Parse("init.rc", strings.NewReader(input), 0)
+			if c.Error == "" {
+				if err != nil {
+					t.Fatalf("unexpected %s error", err)
+				}
+				return
+			}
+			e, ok := err.(*token.Error)
+			if !ok {
+				t.Fatalf("expected *token.Error, got %v", err)
+			}
+			if e.Pos.Line != 1 || !strings.Contains(e.Msg, c.Error) {
+				t.Fatalf("expected error at line 2 containing %q, got %s", c.Error, e)
+			}
+		})
+	}
+}
+
+func TestServiceOptionArgumentPosition(t *testing.T) {
+	_, err := Parse("init.rc", strings.NewReader("service svc /bin/svc\n    socket s stream 999\n"), parser.AllErrors)
+	errs, ok := err.(token.ErrorList)
+	if !ok || len(errs) != 1 {
+		t.Fatalf("expected single error, got %v", err)
+	}
+	if s := errs[0].Error(); s != "init.rc:2:21: socket: argument 3: expected octal permissions, got \"999\"" {
+		t.Fatalf("unexpected error %q", s)
+	}
+}
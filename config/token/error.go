@@ -0,0 +1,152 @@
+package token
+
+import (
+	"fmt"
+	"sort"
+)
+
+// ErrorCode identifies the kind of a syntax error.
+type ErrorCode int
+
+const (
+	// InvalidStatement is reported for statements rejected by a syntax checker.
+	InvalidStatement ErrorCode = iota
+	// UnterminatedQuote is reported for quoted text that is not closed before the end of line.
+	UnterminatedQuote
+	// DanglingEscape is reported for an escape character at the end of input.
+	DanglingEscape
+	// InvalidEscape is reported for unknown or malformed escape sequences.
+	InvalidEscape
+	// InvalidUTF8 is reported for input that is not valid UTF-8.
+	InvalidUTF8
+	// UnknownSection is reported for statements outside of any known section.
+	UnknownSection
+	// InvalidImport is reported for imports that cannot be resolved.
+	InvalidImport
+	// InvalidProperty is reported for property references that cannot be expanded.
+	InvalidProperty
+)
+
+var errorCodes = [...]string{
+	InvalidStatement: "InvalidStatement",
+	UnterminatedQuote: "UnterminatedQuote",
+	DanglingEscape: "DanglingEscape",
+	InvalidEscape: "InvalidEscape",
+	InvalidUTF8: "InvalidUTF8",
+	UnknownSection: "UnknownSection",
+	InvalidImport: "InvalidImport",
+	InvalidProperty: "InvalidProperty",
+}
+
+func (c ErrorCode) String() string {
+	if c < 0 || int(c) >= len(errorCodes) {
+		return fmt.Sprintf("ErrorCode(%d)", int(c))
+	}
+	return errorCodes[c]
+}
+
+// Error is a syntax error at some position in the source.
+type Error struct {
+	Pos Position
+	Code ErrorCode
+	Msg string
+	// Related is a note about another position, e.g. of the previous definition of a
+	// redefined name, or nil.
+	Related *Related
+}
+
+// Related is a note about a position related to an error.
+type Related struct {
+	Pos Position
+	Msg string
+}
+
+// Errorf returns a new syntax error with formatted message.
+func Errorf(pos Position, code ErrorCode, format string, args ...interface{}) *Error {
+	return &Error{
+		Pos: pos,
+		Code: code,
+		Msg: fmt.Sprintf(format, args...),
+	}
+}
+
+// Error formats the error as file:line:column: message.  Filename is omitted if empty.
+func (e *Error) Error() string {
+	return location(e.Pos) + ": " + e.Message()
+}
+
+// Message returns the message followed by the related note, e.g. "service "a" redefined,
+// previous definition at init.rc:3:1".
+func (e *Error) Message() string {
+	if e.Related == nil {
+		return e.Msg
+	}
+	return e.Msg + ", " + e.Related.Msg + " at " + location(e.Related.Pos)
+}
+
+// location formats position as file:line:column.  Filename is omitted if empty.
+func location(pos Position) string {
+	loc := fmt.Sprintf("%d:%d", pos.Line+1, pos.Column+1)
+	if pos.Filename != "" {
+		loc = pos.Filename + ":" + loc
+	}
+	return loc
+}
+
+// ErrorList is a list of syntax errors.
+type ErrorList []*Error
+
+// Add appends an error to the list.  Errors of other types are reported at zero position
+// with InvalidStatement code.
+func (l *ErrorList) Add(err error) {
+	e, ok := err.(*Error)
+	if !ok {
+		e = &Error{Code: InvalidStatement, Msg: err.Error()}
+	}
+	*l = append(*l, e)
+}
+
+func (l ErrorList) Len() int {
+	return len(l)
+}
+
+func (l ErrorList) Swap(i, j int) {
+	l[i], l[j] = l[j], l[i]
+}
+
+func (l ErrorList) Less(i, j int) bool {
+	a, b := l[i], l[j]
+	if a.Pos.Filename != b.Pos.Filename {
+		return a.Pos.Filename < b.Pos.Filename
+	}
+	if a.Pos.Line != b.Pos.Line {
+		return a.Pos.Line < b.Pos.Line
+	}
+	if a.Pos.Column != b.Pos.Column {
+		return a.Pos.Column < b.Pos.Column
+	}
+	return a.Msg < b.Msg
+}
+
+// Sort sorts the list by filename and position.
+func (l ErrorList) Sort() {
+	sort.Stable(l)
+}
+
+func (l ErrorList) Error() string {
+	switch len(l) {
+	case 0:
+		return "no errors"
+	case 1:
+		return l[0].Error()
+	}
+	return fmt.Sprintf("%s (and %d more errors)", l[0], len(l)-1)
+}
+
+// Err returns an error equivalent to this list, or nil if the list is empty.
+func (l ErrorList) Err() error {
+	if len(l) == 0 {
+		return nil
+	}
+	return l
+}
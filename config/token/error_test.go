@@ -0,0 +1,34 @@
+package token
+
+import (
+	"testing"
+)
+
+func TestErrorListSort(t *testing.T) {
+	errs := ErrorList{
+		{Pos: Position{Filename: "b", Line: 0, Column: 0}, Msg: "b1"},
+		{Pos: Position{Filename: "a", Line: 2, Column: 1}, Msg: "a3"},
+		{Pos: Position{Filename: "a", Line: 1, Column: 5}, Msg: "a2"},
+		{Pos: Position{Filename: "a", Line: 1, Column: 0}, Msg: "a1"},
+	}
+	errs.Sort()
+	for i, want := range []string{"a1", "a2", "a3", "b1"} {
+		if errs[i].Msg != want {
+			t.Fatalf("expected %s at %d, got %s", want, i, errs[i].Msg)
+		}
+	}
+	if s := errs.Error(); s != "a:2:1: a1 (and 3 more errors)" {
+		t.Fatalf("unexpected error string %q", s)
+	}
+	if ErrorList(nil).Err() != nil {
+		t.Fatal("expected nil error for empty list")
+	}
+}
+
+func TestErrorRelated(t *testing.T) {
+	e := Errorf(Position{Filename: "b.rc", Line: 1}, InvalidStatement, "service %q redefined", "a")
+	e.Related = &Related{Pos: Position{Filename: "a.rc", Line: 2, Column: 4}, Msg: "previous definition"}
+	if s := e.Error(); s != "b.rc:2:1: service \"a\" redefined, previous definition at a.rc:3:5" {
+		t.Fatalf("unexpected error string %q", s)
+	}
+}
@@ -4,14 +4,32 @@ import (
 	"fmt"
 )
 
+// Position is a location in the source file.  Line and Column are zero-based,
+// Offset is in bytes.  Filename may be empty if the source has no name.
 type Position struct {
+	Filename string
 	Offset, Line, Column int
 }
 
 func (p Position) String() string {
-	return fmt.Sprintf(
+	s := fmt.Sprintf(
 		"%d:%d(%+d)",
 		p.Line+1, p.Column+1,
 		p.Offset,
 	)
+	if p.Filename != "" {
+		s = p.Filename + ":" + s
+	}
+	return s
+}
+
+// advance returns the position q relative to p as an absolute position.
+func (p Position) advance(q Position) Position {
+	q.Filename = p.Filename
+	if q.Line == 0 {
+		q.Column += p.Column
+	}
+	q.Line += p.Line
+	q.Offset += p.Offset
+	return q
 }
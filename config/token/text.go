@@ -0,0 +1,301 @@
+package token
+
+import (
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Dialect is the quoting dialect of text tokens, see lexer.Options.  A nil *Dialect is the
+// default dialect of init config files.
+type Dialect struct {
+	// Comments are runes that start comments.
+	Comments []rune
+	// Quotes are quote runes of text with escape sequences.
+	Quotes []rune
+	// LiteralQuotes are quote runes of literal text.
+	LiteralQuotes []rune
+	// Escape is the rune that starts escape sequences.  Negative values disable escapes.
+	Escape rune
+}
+
+// defaultDialect is the dialect of nil *Dialect.
+var defaultDialect = Dialect{
+	Comments: []rune{'#'},
+	Quotes: []rune{'"'},
+	LiteralQuotes: []rune{'\''},
+	Escape: '\\',
+}
+
+// IsDefault reports whether d is the default dialect.
+func (d *Dialect) IsDefault() bool {
+	return d == nil || equalRunes(d.Comments, defaultDialect.Comments) &&
+		equalRunes(d.Quotes, defaultDialect.Quotes) &&
+		equalRunes(d.LiteralQuotes, defaultDialect.LiteralQuotes) &&
+		d.Escape == defaultDialect.Escape
+}
+
+func (d *Dialect) get() *Dialect {
+	if d == nil {
+		return &defaultDialect
+	}
+	return d
+}
+
+// escapes reports whether r starts escape sequences.
+func (d *Dialect) escapes(r rune) bool {
+	return d.Escape >= 0 && r == d.Escape
+}
+
+// Text returns the decoded value of a text token, i.e. with quotes removed and escape sequences
+// processed.  Values of other token types are returned as is.  The raw form is always available
+// in the Val field.  Decoding errors are positioned relative to the token position.
+// Tokens are decoded in their dialect, see Dialect.Unquote.
+func (t Token) Text() (string, error) {
+	if t.Typ != TextToken {
+		return t.Val, nil
+	}
+	s, err := t.Dialect.Unquote(t.Val)
+	if err != nil {
+		err := *err.(*Error)
+		err.Pos = t.Pos.advance(err.Pos)
+		return "", &err
+	}
+	return s, nil
+}
+
+// Unquote decodes the raw value of a text token of the default dialect.
+//
+// Parts of the value may be enclosed in double or single quotes, so that spaces and comment
+// characters are taken literally; adjacent parts are concatenated, e.g. a"b c"d is decoded as
+// "ab cd".  Single-quoted parts are taken literally as a whole, backslash included.  Both inside
+// double quotes and outside of quotes the following escape sequences are recognized:
+//
+//	\n \t \r      newline, tab and carriage return
+//	\\ \" \' \#   backslash, double quote, single quote and hash
+//	\<space>      space
+//	\xNN          byte with hexadecimal value NN
+//	\u{N...}      Unicode code point with hexadecimal value N... (up to 6 digits)
+//	\<newline>    line continuation, decoded as nothing; newline may be either LF or CRLF
+//
+// Any other escape sequence is an error.  Errors are of *Error type and positioned relative to the
+// start of s.
+func Unquote(s string) (string, error) {
+	return (*Dialect)(nil).Unquote(s)
+}
+
+// Unquote decodes the raw value of a text token of the dialect.  Runes of Quotes and
+// LiteralQuotes work like double and single quotes of the default dialect, and escape
+// sequences start with the Escape rune instead of backslash, e.g. with ^ escape ^n is
+// a newline and ^^ is ^.  Comment runes, quote runes and the escape rune itself are escaped
+// like hash, quotes and backslash.  See Unquote.
+func (d *Dialect) Unquote(s string) (string, error) {
+	d = d.get()
+	// fast path: nothing to decode
+	if !strings.ContainsFunc(s, func(r rune) bool {
+		return d.escapes(r) || contains(d.Quotes, r) || contains(d.LiteralQuotes, r)
+	}) {
+		return s, nil
+	}
+	var b strings.Builder
+	// quote is the opening quote of the current quoted part, if any
+	quote, quotePos := rune(-1), 0
+	for i := 0; i < len(s); {
+		r, size := utf8.DecodeRuneInString(s[i:])
+		switch {
+		case quote < 0 && contains(d.Quotes, r):
+			quote, quotePos = r, i
+			i += size
+			continue
+		case quote >= 0 && r == quote:
+			quote = -1
+			i += size
+			continue
+		case quote < 0 && contains(d.LiteralQuotes, r):
+			end := strings.IndexRune(s[i+size:], r)
+			if end < 0 {
+				return "", Errorf(offsetPosition(s, i), UnterminatedQuote, "unterminated quoted string")
+			}
+			b.WriteString(s[i+size : i+size+end])
+			i += 2*size + end
+			continue
+		case d.escapes(r):
+			n, err := d.unescape(&b, s[i:])
+			if err != nil {
+				err.Pos = offsetPosition(s, i)
+				return "", err
+			}
+			i += n
+			continue
+		}
+		b.WriteString(s[i : i+size])
+		i += size
+	}
+	if quote >= 0 {
+		return "", Errorf(offsetPosition(s, quotePos), UnterminatedQuote, "unterminated quoted string")
+	}
+	return b.String(), nil
+}
+
+// unescape decodes an escape sequence at the start of s and writes it to b.
+// It returns the length of the sequence.  Position of the returned error is not set.
+func (d *Dialect) unescape(b *strings.Builder, s string) (int, *Error) {
+	e, n := utf8.DecodeRuneInString(s)
+	if len(s) <= n {
+		return 0, &Error{Code: DanglingEscape, Msg: "escape sequence not terminated"}
+	}
+	c, size := utf8.DecodeRuneInString(s[n:])
+	switch {
+	case c == 'n':
+		b.WriteByte('\n')
+	case c == 't':
+		b.WriteByte('\t')
+	case c == 'r':
+		b.WriteByte('\r')
+	case c == e || c == ' ' || contains(d.Quotes, c) || contains(d.LiteralQuotes, c) || contains(d.Comments, c):
+		b.WriteRune(c)
+	case c == '\n':
+		// line continuation
+	case c == '\r':
+		if len(s) < n+2 || s[n+1] != '\n' {
+			return 0, invalidEscape("unknown escape sequence %c%c", e, c)
+		}
+		// line continuation with CRLF separator
+		return n + 2, nil
+	case c == 'x':
+		if len(s) < n+3 {
+			return 0, invalidEscape("invalid escape sequence %q: expected two hexadecimal digits", s)
+		}
+		v, err := strconv.ParseUint(s[n+1:n+3], 16, 8)
+		if err != nil {
+			return 0, invalidEscape("invalid escape sequence %q: expected two hexadecimal digits", s[:n+3])
+		}
+		b.WriteByte(byte(v))
+		return n + 3, nil
+	case c == 'u':
+		end := strings.IndexByte(s, '}')
+		if len(s) < n+2 || s[n+1] != '{' || end < 0 {
+			return 0, invalidEscape("invalid escape sequence %q: expected %cu{...}", s[:n+1], e)
+		}
+		digits := s[n+2 : end]
+		if len(digits) < 1 || len(digits) > 6 {
+			return 0, invalidEscape("invalid escape sequence %q: expected 1 to 6 hexadecimal digits", s[:end+1])
+		}
+		v, err := strconv.ParseUint(digits, 16, 32)
+		if err != nil || !utf8.ValidRune(rune(v)) {
+			return 0, invalidEscape("invalid escape sequence %q: not a valid code point", s[:end+1])
+		}
+		b.WriteRune(rune(v))
+		return end + 1, nil
+	default:
+		return 0, invalidEscape("unknown escape sequence %c%c", e, c)
+	}
+	return n + size, nil
+}
+
+func invalidEscape(format string, args ...interface{}) *Error {
+	return Errorf(Position{}, InvalidEscape, format, args...)
+}
+
+// offsetPosition returns position of the byte offset i in s.
+func offsetPosition(s string, i int) Position {
+	s = s[:i]
+	line := strings.Count(s, "\n")
+	if n := strings.LastIndexByte(s, '\n'); n >= 0 {
+		s = s[n+1:]
+	}
+	return Position{
+		Offset: i,
+		Line: line,
+		Column: utf8.RuneCountInString(s),
+	}
+}
+
+// Quote returns the canonical text token form of the decoded value s in the default dialect,
+// such that Unquote returns s.  Values without special characters are left bare, values with backslashes
+// that can be represented literally are single-quoted, and other values are double-quoted
+// with escape sequences for quotes, backslashes and control characters.
+func Quote(s string) string {
+	if s == "" {
+		return "\"\""
+	}
+	if !needsQuotes(s) {
+		return s
+	}
+	if strings.IndexByte(s, '\\') >= 0 && literal(s) {
+		return "'" + s + "'"
+	}
+	var b strings.Builder
+	b.WriteByte('"')
+	for i := 0; i < len(s); {
+		r, size := utf8.DecodeRuneInString(s[i:])
+		switch {
+		case r == utf8.RuneError && size == 1:
+			b.WriteString("\\x")
+			b.WriteString(strconv.FormatUint(uint64(s[i])|0x100, 16)[1:])
+		case r == '"' || r == '\\':
+			b.WriteByte('\\')
+			b.WriteRune(r)
+		case r == '\n':
+			b.WriteString("\\n")
+		case r == '\t':
+			b.WriteString("\\t")
+		case r == '\r':
+			b.WriteString("\\r")
+		case !strconv.IsPrint(r) && r != ' ':
+			b.WriteString("\\u{")
+			b.WriteString(strconv.FormatInt(int64(r), 16))
+			b.WriteByte('}')
+		default:
+			b.WriteRune(r)
+		}
+		i += size
+	}
+	b.WriteByte('"')
+	return b.String()
+}
+
+// needsQuotes reports whether s cannot be represented as a bare text token.
+func needsQuotes(s string) bool {
+	for _, r := range s {
+		switch {
+		case r == '"' || r == '\'' || r == '\\' || r == '#':
+			return true
+		case unicode.IsSpace(r) || !strconv.IsPrint(r):
+			return true
+		}
+	}
+	return !utf8.ValidString(s)
+}
+
+func contains(runes []rune, r rune) bool {
+	for _, c := range runes {
+		if c == r {
+			return true
+		}
+	}
+	return false
+}
+
+func equalRunes(a, b []rune) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// literal reports whether s can be single-quoted as is.
+func literal(s string) bool {
+	for _, r := range s {
+		if r == '\'' || r != ' ' && (unicode.IsSpace(r) || !strconv.IsPrint(r)) {
+			return false
+		}
+	}
+	return utf8.ValidString(s)
+}
@@ -0,0 +1,173 @@
+package token
+
+import (
+	"testing"
+)
+
+func TestUnquote(t *testing.T) {
+	cases := []struct {
+		Name string
+		Input string
+		Output string
+		Error bool
+	}{
+		{Name: "Plain", Input: "foo", Output: "foo"},
+		{Name: "Quoted", Input: "\"foo bar\"", Output: "foo bar"},
+		{Name: "Empty", Input: "\"\"", Output: ""},
+		{Name: "Concat", Input: "a\"b c\"d", Output: "ab cd"},
+		{Name: "EscapedSpace", Input: "a\\ b", Output: "a b"},
+		{Name: "EscapedHash", Input: "\\#a", Output: "#a"},
+		{Name: "EscapedQuote", Input: "\"a\\\"b\"", Output: "a\"b"},
+		{Name: "EscapedEscape", Input: "a\\\\b", Output: "a\\b"},
+		{Name: "Controls", Input: "\\n\\t\\r", Output: "\n\t\r"},
+		{Name: "Hex", Input: "\\x41\\x7e", Output: "A~"},
+		{Name: "Unicode", Input: "\"\\u{41}\\u{263a}\"", Output: "A☺"},
+		{Name: "LineContinuation", Input: "a\\\n", Output: "a"},
+		{Name: "QuotedLineContinuation", Input: "\"a\\\nb\"", Output: "ab"},
+		{Name: "LineContinuationCRLF", Input: "a\\\r\nb", Output: "ab"},
+		{Name: "SingleQuoted", Input: "'a \\q \"b'", Output: "a \\q \"b"},
+		{Name: "SingleQuotedConcat", Input: "a'b'\"c'\"", Output: "abc'"},
+		{Name: "EscapedSingleQuote", Input: "\\'a", Output: "'a"},
+		{Name: "UnterminatedSingleQuote", Input: "'a", Error: true},
+		{Name: "UnknownEscape", Input: "\\q", Error: true},
+		{Name: "DanglingEscape", Input: "a\\", Error: true},
+		{Name: "UnterminatedQuote", Input: "\"a", Error: true},
+		{Name: "ShortHex", Input: "\\x4", Error: true},
+		{Name: "BadHex", Input: "\\xzz", Error: true},
+		{Name: "UnicodeNoBraces", Input: "\\u0041", Error: true},
+		{Name: "UnicodeUnterminated", Input: "\\u{41", Error: true},
+		{Name: "UnicodeEmpty", Input: "\\u{}", Error: true},
+		{Name: "UnicodeSurrogate", Input: "\\u{d800}", Error: true},
+		{Name: "UnicodeTooLarge", Input: "\\u{110000}", Error: true},
+	}
+	for _, c := range cases {
+		c := c
+		t.Run(c.Name, func(t *testing.T) {
+			s, err := Unquote(c.Input)
+			if c.Error {
+				if err == nil {
+					t.Fatalf("expected error, got %q", s)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected %s error", err)
+			}
+			if s != c.Output {
+				t.Fatalf("expected %q, got %q", c.Output, s)
+			}
+		})
+	}
+}
+
+func TestTokenText(t *testing.T) {
+	tok := Token{Typ: TextToken, Val: "\"a b\""}
+	if s, err := tok.Text(); err != nil || s != "a b" {
+		t.Fatalf("expected %q, got %q (error %v)", "a b", s, err)
+	}
+	tok = Token{Typ: CommentToken, Val: "# \"a\\q"}
+	if s, err := tok.Text(); err != nil || s != tok.Val {
+		t.Fatalf("expected %q, got %q (error %v)", tok.Val, s, err)
+	}
+	tok = Token{Typ: TextToken, Val: "a\\\n\"b\\q\"", Pos: Position{Offset: 10, Line: 2, Column: 4}}
+	_, err := tok.Text()
+	want := &Error{Pos: Position{Offset: 15, Line: 3, Column: 2}, Code: InvalidEscape, Msg: "unknown escape sequence \\q"}
+	if e, ok := err.(*Error); !ok || *e != *want {
+		t.Fatalf("expected %#v error, got %#v", want, err)
+	}
+}
+
+func TestDialectUnquote(t *testing.T) {
+	d := &Dialect{
+		Comments: []rune{';'},
+		Quotes: []rune{'`', '«'},
+		LiteralQuotes: []rune{'|'},
+		Escape: '^',
+	}
+	cases := []struct {
+		Name string
+		Input string
+		Output string
+		Error bool
+	}{
+		{Name: "Plain", Input: "a\\b\"'#", Output: "a\\b\"'#"},
+		{Name: "Quoted", Input: "`a b`«c`d«", Output: "a bc`d"},
+		{Name: "Literal", Input: "|a^q`|", Output: "a^q`"},
+		{Name: "Escapes", Input: "^^^;^`^|^ ^n^x41^u{263a}", Output: "^;`| \nA☺"},
+		{Name: "LineContinuation", Input: "a^\r\n", Output: "a"},
+		{Name: "EscapedHash", Input: "^#", Error: true},
+		{Name: "UnterminatedQuote", Input: "«a", Error: true},
+		{Name: "DanglingEscape", Input: "a^", Error: true},
+	}
+	for _, c := range cases {
+		t.Run(c.Name, func(t *testing.T) {
+			s, err := d.Unquote(c.Input)
+			if c.Error {
+				if err == nil {
+					t.Fatalf("expected error, got %q", s)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected %s error", err)
+			}
+			if s != c.Output {
+				t.Fatalf("expected %q, got %q", c.Output, s)
+			}
+		})
+	}
+	if s, err := (&Dialect{Escape: -1}).Unquote("a\\n"); err != nil || s != "a\\n" {
+		t.Fatalf("expected literal backslash, got %q (error %v)", s, err)
+	}
+}
+
+func TestFolding(t *testing.T) {
+	d := &Dialect{Escape: '^'}
+	cases := []struct {
+		Tok Token
+		Folding bool
+	}{
+		{Token{Typ: TextToken, Val: "\\\n"}, true},
+		{Token{Typ: TextToken, Val: "\\\r\n"}, true},
+		{Token{Typ: TextToken, Val: "\\\n", Dialect: d}, false},
+		{Token{Typ: TextToken, Val: "^\r\n", Dialect: d}, true},
+		{Token{Typ: TextToken, Val: "^\n", Dialect: &Dialect{Escape: -1}}, false},
+		{Token{Typ: SepToken, Val: "\n"}, false},
+	}
+	for _, c := range cases {
+		if c.Tok.Folding() != c.Folding {
+			t.Errorf("expected %v folding of %s", c.Folding, c.Tok)
+		}
+	}
+}
+
+func TestQuote(t *testing.T) {
+	cases := []struct {
+		Input string
+		Output string
+	}{
+		{Input: "foo", Output: "foo"},
+		{Input: "", Output: "\"\""},
+		{Input: "a b", Output: "\"a b\""},
+		{Input: "#a", Output: "\"#a\""},
+		{Input: "a\"b", Output: "\"a\\\"b\""},
+		{Input: "it's", Output: "\"it's\""},
+		{Input: "^a\\d+$", Output: "'^a\\d+$'"},
+		{Input: "C:\\dir name", Output: "'C:\\dir name'"},
+		{Input: "a\\'b", Output: "\"a\\\\'b\""},
+		{Input: "a\nb\tc", Output: "\"a\\nb\\tc\""},
+		{Input: "\x00\x7f", Output: "\"\\u{0}\\u{7f}\""},
+		{Input: "\xff", Output: "\"\\xff\""},
+		{Input: "☺", Output: "☺"},
+	}
+	for _, c := range cases {
+		s := Quote(c.Input)
+		if s != c.Output {
+			t.Errorf("Quote(%q): expected %q, got %q", c.Input, c.Output, s)
+		}
+		u, err := Unquote(s)
+		if err != nil || u != c.Input {
+			t.Errorf("Unquote(%q): expected %q, got %q (error %v)", s, c.Input, u, err)
+		}
+	}
+}
@@ -2,6 +2,7 @@ package token
 
 import (
 	"fmt"
+	"unicode/utf8"
 )
 
 type TokenType string
@@ -18,6 +19,30 @@ type Token struct {
 	Val string
 	Pos Position
 	End Position
+	// Raw is the source of the token if it differs from Val, i.e. if invalid UTF-8 sequences
+	// are replaced with U+FFFD in Val.  It is only set by lexers reading from bytes.
+	Raw string
+	// Dialect is the quoting dialect of the token, nil for the default one.
+	Dialect *Dialect
+}
+
+// Source returns the source of the token.
+func (t Token) Source() string {
+	if t.Raw != "" {
+		return t.Raw
+	}
+	return t.Val
+}
+
+// Folding reports whether the token is a line folding, i.e. an escaped separator that joins
+// the next line to the statement.
+func (t Token) Folding() bool {
+	if t.Typ != TextToken {
+		return false
+	}
+	d := t.Dialect.get()
+	r, n := utf8.DecodeRuneInString(t.Val)
+	return d.escapes(r) && (t.Val[n:] == "\n" || t.Val[n:] == "\r\n")
 }
 
 func (t Token) String() string {
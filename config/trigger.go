@@ -0,0 +1,496 @@
+package config
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/tie/x/config/parser"
+	"github.com/tie/x/config/token"
+)
+
+// Condition is a single trigger condition, either an event or a property trigger.
+//
+//	<event>
+//	property:<name>=<value>
+//	property:<name>=*
+type Condition struct {
+	Pos token.Position
+	// Event is the event name of event triggers, empty for property triggers.
+	Event string
+	// Property and Value are the property name and the expected value of property triggers.
+	// Value "*" matches any value.
+	Property, Value string
+}
+
+// IsProperty reports whether the condition is a property trigger.
+func (c Condition) IsProperty() bool {
+	return c.Event == ""
+}
+
+// Match reports whether the property value satisfies the property trigger.
+func (c Condition) Match(value string) bool {
+	return c.Value == "*" || c.Value == value
+}
+
+func (c Condition) String() string {
+	if c.IsProperty() {
+		return "property:" + c.Property + "=" + c.Value
+	}
+	return c.Event
+}
+
+// Event returns the event name of the trigger, or empty string if the trigger has
+// only property conditions.
+func (t *Trigger) Event() string {
+	for _, c := range t.Conditions {
+		if !c.IsProperty() {
+			return c.Event
+		}
+	}
+	return ""
+}
+
+// triggerCommands is the schema of Android init commands executed by triggers.
+var triggerCommands = map[string]optionSpec{
+	"bootchart": {
+		Usage: "bootchart <start|stop>",
+		Min: 1, Max: 1,
+		Args: []argCheck{argEnum("start", "stop")},
+	},
+	"chmod": {
+		Usage: "chmod <octal-mode> <path>",
+		Min: 2, Max: 2,
+		Args: []argCheck{expanded(argOctal), argPath},
+	},
+	"chown": {
+		Usage: "chown <owner> [ <group> ] <path>",
+		Min: 2, Max: 3,
+		Check: lastArg(argPath),
+	},
+	"class_reset": {
+		Usage: "class_reset <serviceclass>",
+		Min: 1, Max: 1,
+	},
+	"class_reset_post_data": {
+		Usage: "class_reset_post_data <serviceclass>",
+		Min: 1, Max: 1,
+	},
+	"class_restart": {
+		Usage: "class_restart [ --only-enabled ] <serviceclass>",
+		Min: 1, Max: 2,
+		Check: checkFlag("--only-enabled"),
+	},
+	"class_start": {
+		Usage: "class_start <serviceclass>",
+		Min: 1, Max: 1,
+	},
+	"class_start_post_data": {
+		Usage: "class_start_post_data <serviceclass>",
+		Min: 1, Max: 1,
+	},
+	"class_stop": {
+		Usage: "class_stop <serviceclass>",
+		Min: 1, Max: 1,
+	},
+	"copy": {
+		Usage: "copy <src> <dst>",
+		Min: 2, Max: 2,
+		Args: []argCheck{argPath},
+	},
+	"copy_per_line": {
+		Usage: "copy_per_line <src> <dst>",
+		Min: 2, Max: 2,
+		Args: []argCheck{argPath},
+	},
+	"domainname": {
+		Usage: "domainname <name>",
+		Min: 1, Max: 1,
+	},
+	"enable": {
+		Usage: "enable <servicename>",
+		Min: 1, Max: 1,
+	},
+	"enter_default_mount_ns": {
+		Usage: "enter_default_mount_ns",
+	},
+	"exec": {
+		Usage: "exec [ <seclabel> [ <user> [ <group> ]* ] ] -- <command> [ <argument> ]*",
+		Min: 2, Max: -1,
+		Check: checkExec,
+	},
+	"exec_background": {
+		Usage: "exec_background [ <seclabel> [ <user> [ <group> ]* ] ] -- <command> [ <argument> ]*",
+		Min: 2, Max: -1,
+		Check: checkExec,
+	},
+	"exec_start": {
+		Usage: "exec_start <service>",
+		Min: 1, Max: 1,
+	},
+	"export": {
+		Usage: "export <name> <value>",
+		Min: 2, Max: 2,
+		Args: []argCheck{argEnvName, nil},
+	},
+	"hostname": {
+		Usage: "hostname <name>",
+		Min: 1, Max: 1,
+	},
+	"ifup": {
+		Usage: "ifup <interface>",
+		Min: 1, Max: 1,
+	},
+	"init_user0": {
+		Usage: "init_user0",
+	},
+	"insmod": {
+		Usage: "insmod [ -f ] <path> [ <options> ]",
+		Min: 1, Max: -1,
+	},
+	"installkey": {
+		Usage: "installkey <dir>",
+		Min: 1, Max: 1,
+		Args: []argCheck{argPath},
+	},
+	"interface_restart": {
+		Usage: "interface_restart <name>",
+		Min: 1, Max: 1,
+	},
+	"interface_start": {
+		Usage: "interface_start <name>",
+		Min: 1, Max: 1,
+	},
+	"interface_stop": {
+		Usage: "interface_stop <name>",
+		Min: 1, Max: 1,
+	},
+	"load_exports": {
+		Usage: "load_exports <path>",
+		Min: 1, Max: 1,
+		Args: []argCheck{argPath},
+	},
+	"load_persist_props": {
+		Usage: "load_persist_props",
+	},
+	"load_system_props": {
+		Usage: "load_system_props",
+	},
+	"loglevel": {
+		Usage: "loglevel <level>",
+		Min: 1, Max: 1,
+		Args: []argCheck{expanded(argInt(0, 7))},
+	},
+	"mark_post_data": {
+		Usage: "mark_post_data",
+	},
+	"mkdir": {
+		Usage: "mkdir <path> [ <mode> ] [ <owner> ] [ <group> ] [ encryption=<action> ] [ key=<key> ]",
+		Min: 1, Max: 6,
+		Args: []argCheck{argPath, expanded(argOctal), nil, nil, argMkdirOption},
+		Check: checkMkdir,
+	},
+	"mount": {
+		Usage: "mount <type> <device> <dir> [ <flag> ]* [ <options> ]",
+		Min: 3, Max: -1,
+	},
+	"mount_all": {
+		Usage: "mount_all [ <fstab> ] [ --<option> ]",
+		Min: 0, Max: -1,
+	},
+	"perform_apex_config": {
+		Usage: "perform_apex_config [ --bootstrap ]",
+		Min: 0, Max: 1,
+		Args: []argCheck{argEnum("--bootstrap")},
+	},
+	"readahead": {
+		Usage: "readahead <file|dir> [ --fully ]",
+		Min: 1, Max: 2,
+		Args: []argCheck{argPath, argEnum("--fully")},
+	},
+	"remount_userdata": {
+		Usage: "remount_userdata",
+	},
+	"restart": {
+		Usage: "restart [ --only-if-running ] <service>",
+		Min: 1, Max: 2,
+		Check: checkFlag("--only-if-running"),
+	},
+	"restorecon": {
+		Usage: "restorecon <path> [ <path> ]*",
+		Min: 1, Max: -1,
+	},
+	"restorecon_recursive": {
+		Usage: "restorecon_recursive <path> [ <path> ]*",
+		Min: 1, Max: -1,
+	},
+	"rm": {
+		Usage: "rm <path>",
+		Min: 1, Max: 1,
+		Args: []argCheck{argPath},
+	},
+	"rmdir": {
+		Usage: "rmdir <path>",
+		Min: 1, Max: 1,
+		Args: []argCheck{argPath},
+	},
+	"setprop": {
+		Usage: "setprop <name> <value>",
+		Min: 2, Max: 2,
+		Args: []argCheck{argPropertyName, nil},
+	},
+	"setrlimit": {
+		Usage: "setrlimit <resource> <cur> <max>",
+		Min: 3, Max: 3,
+	},
+	"start": {
+		Usage: "start <service>",
+		Min: 1, Max: 1,
+	},
+	"stop": {
+		Usage: "stop <service>",
+		Min: 1, Max: 1,
+	},
+	"swapon_all": {
+		Usage: "swapon_all [ <fstab> ]",
+		Min: 0, Max: 1,
+	},
+	"symlink": {
+		Usage: "symlink <target> <path>",
+		Min: 2, Max: 2,
+		Args: []argCheck{nil, argPath},
+	},
+	"sysclktz": {
+		Usage: "sysclktz <minutes_west_of_gmt>",
+		Min: 1, Max: 1,
+	},
+	"trigger": {
+		Usage: "trigger <event>",
+		Min: 1, Max: 1,
+		Args: []argCheck{argEvent},
+	},
+	"umount": {
+		Usage: "umount <path>",
+		Min: 1, Max: 1,
+	},
+	"umount_all": {
+		Usage: "umount_all [ <fstab> ]",
+		Min: 0, Max: 1,
+	},
+	"update_linker_config": {
+		Usage: "update_linker_config",
+	},
+	"verity_update_state": {
+		Usage: "verity_update_state",
+	},
+	"wait": {
+		Usage: "wait <path> [ <timeout> ]",
+		Min: 1, Max: 2,
+		Args: []argCheck{argPath, expanded(argInt(0, -1))},
+	},
+	"wait_for_prop": {
+		Usage: "wait_for_prop <name> <value>",
+		Min: 2, Max: 2,
+		Args: []argCheck{argPropertyName, nil},
+	},
+	"write": {
+		Usage: "write <path> <content>",
+		Min: 2, Max: 2,
+		Args: []argCheck{argPath, nil},
+	},
+}
+
+func triggerCheck(stmt parser.Statement) error {
+	args, err := stmt.Text()
+	if err != nil {
+		return err
+	}
+	if stmt.Directive() == "on" {
+		_, err := parseConditions(stmt)
+		return err
+	}
+	spec, ok := triggerCommands[args[0]]
+	if !ok {
+		if s := suggest(args[0], triggerCommands); s != "" {
+			return fmt.Errorf("unknown command %q, did you mean %q?", args[0], s)
+		}
+		return fmt.Errorf("unknown command %q", args[0])
+	}
+	return spec.check(stmt, args[1:])
+}
+
+// CheckCommand checks arguments of the trigger command against the schema, e.g. after
+// properties are expanded.  Unknown commands are errors.
+func CheckCommand(cmd Command) error {
+	spec, ok := triggerCommands[cmd.Name]
+	if !ok {
+		return fmt.Errorf("unknown command %q", cmd.Name)
+	}
+	i, err := spec.checkArgs(cmd.Args)
+	switch {
+	case err == nil:
+		return nil
+	case i >= 0:
+		return fmt.Errorf("argument %d: %s", i+1, err)
+	}
+	return fmt.Errorf("%s, usage: %s", err, spec.Usage)
+}
+
+// checkExec checks that the command follows the -- separator.
+func checkExec(args []string) error {
+	for i, arg := range args {
+		if arg == "--" {
+			if i == len(args)-1 {
+				return fmt.Errorf("expected command after --")
+			}
+			return nil
+		}
+	}
+	return fmt.Errorf("expected -- before command")
+}
+
+// checkFlag returns a check of the optional flag preceding the single operand.
+func checkFlag(flag string) func(args []string) error {
+	return func(args []string) error {
+		if len(args) == 2 && args[0] != flag {
+			return fmt.Errorf("expected %s, got %q", flag, args[0])
+		}
+		return nil
+	}
+}
+
+// checkMkdir checks that encryption and key options follow the mode, owner and group, and
+// that each option is given once.
+func checkMkdir(args []string) error {
+	seen := map[string]bool{}
+	for i, arg := range args {
+		key := mkdirOption(arg)
+		if key == "" {
+			continue
+		}
+		if i < 4 {
+			return fmt.Errorf("%s must follow <mode> <owner> <group>", key)
+		}
+		if seen[key] {
+			return fmt.Errorf("duplicate %s option", key)
+		}
+		seen[key] = true
+	}
+	return nil
+}
+
+// mkdirOption returns the key of the mkdir option argument, or empty string if the argument
+// is not an option.
+func mkdirOption(arg string) string {
+	for _, key := range []string{"encryption", "key"} {
+		if strings.HasPrefix(arg, key+"=") {
+			return key
+		}
+	}
+	return ""
+}
+
+func argMkdirOption(arg string) error {
+	switch mkdirOption(arg) {
+	case "encryption":
+		return argEnum("None", "Require", "Attempt", "DeleteIfNecessary")(strings.TrimPrefix(arg, "encryption="))
+	case "key":
+		return argEnum("ref", "per_boot_ref")(strings.TrimPrefix(arg, "key="))
+	}
+	return fmt.Errorf("expected encryption=<action> or key=<key>, got %q", arg)
+}
+
+// expanded returns a check that accepts arguments starting with a property reference as is,
+// since properties are expanded on execution.
+func expanded(check argCheck) argCheck {
+	return func(arg string) error {
+		if strings.HasPrefix(arg, "$") {
+			return nil
+		}
+		return check(arg)
+	}
+}
+
+// lastArg returns a check of the last argument.
+func lastArg(check argCheck) func(args []string) error {
+	return func(args []string) error {
+		return check(args[len(args)-1])
+	}
+}
+
+// argPath checks for absolute paths.  Paths starting with a property reference are accepted
+// as is, since properties are expanded on execution.
+func argPath(arg string) error {
+	if !strings.HasPrefix(arg, "/") && !strings.HasPrefix(arg, "$") {
+		return fmt.Errorf("expected absolute path, got %q", arg)
+	}
+	return nil
+}
+
+func argPropertyName(arg string) error {
+	if arg == "" || strings.ContainsAny(arg, "= \t\n") {
+		return fmt.Errorf("invalid property name %q", arg)
+	}
+	return nil
+}
+
+func argEvent(arg string) error {
+	_, err := parseCondition(arg)
+	if err == nil && strings.HasPrefix(arg, "property:") {
+		return fmt.Errorf("expected event, got property trigger %q", arg)
+	}
+	return err
+}
+
+// parseConditions parses trigger conditions from the "on" section header.
+// Conditions are separated by "&&", and at most one event condition is allowed.
+func parseConditions(stmt parser.Statement) ([]Condition, error) {
+	args := text(stmt)
+	if len(args) < 2 {
+		return nil, fmt.Errorf("on requires a trigger")
+	}
+	var conds []Condition
+	event := -1
+	for i := 1; i < len(args); i += 2 {
+		arg, pos := args[i], stmt[i].Pos
+		if arg == "&&" {
+			return nil, token.Errorf(pos, token.InvalidStatement, "expected trigger condition, got &&")
+		}
+		if i+1 < len(args) && args[i+1] != "&&" {
+			return nil, token.Errorf(stmt[i+1].Pos, token.InvalidStatement, "expected &&, got %q", args[i+1])
+		}
+		if i+1 == len(args)-1 {
+			return nil, token.Errorf(stmt[i+1].Pos, token.InvalidStatement, "expected trigger condition after &&")
+		}
+		cond, err := parseCondition(arg)
+		if err != nil {
+			return nil, token.Errorf(pos, token.InvalidStatement, "%s", err)
+		}
+		cond.Pos = pos
+		if !cond.IsProperty() {
+			if event >= 0 {
+				return nil, token.Errorf(pos, token.InvalidStatement, "multiple event triggers %q and %q", conds[event].Event, cond.Event)
+			}
+			event = len(conds)
+		}
+		conds = append(conds, cond)
+	}
+	return conds, nil
+}
+
+func parseCondition(arg string) (Condition, error) {
+	if !strings.HasPrefix(arg, "property:") {
+		if arg == "" || strings.ContainsAny(arg, "=:") {
+			return Condition{}, fmt.Errorf("invalid event trigger %q", arg)
+		}
+		return Condition{Event: arg}, nil
+	}
+	prop := strings.TrimPrefix(arg, "property:")
+	i := strings.IndexByte(prop, '=')
+	if i < 0 {
+		return Condition{}, fmt.Errorf("invalid property trigger %q: expected property:<name>=<value>", arg)
+	}
+	if i == 0 {
+		return Condition{}, fmt.Errorf("invalid property trigger %q: empty property name", arg)
+	}
+	return Condition{Property: prop[:i], Value: prop[i+1:]}, nil
+}
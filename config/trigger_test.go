@@ -0,0 +1,378 @@
+package config
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/tie/x/config/parser"
+	"github.com/tie/x/config/token"
+)
+
+func TestTriggerConditions(t *testing.T) {
+	cases := []struct {
+		Header string
+		Conditions []string
+		Event string
+		// Error is a substring of the expected error message, empty if no error is expected.
+		Error string
+	}{
+		{Header: "on boot", Conditions: []string{"boot"}, Event: "boot"},
+		{Header: "on early-init", Conditions: []string{"early-init"}, Event: "early-init"},
+		{Header: "on property:sys.boot_completed=1", Conditions: []string{"property:sys.boot_completed=1"}},
+		{Header: "on property:sys.x=*", Conditions: []string{"property:sys.x=*"}},
+		{Header: "on property:sys.x=", Conditions: []string{"property:sys.x="}},
+		{
+			Header: "on boot && property:a=1 && property:b=*",
+			Conditions: []string{"boot", "property:a=1", "property:b=*"},
+			Event: "boot",
+		},
+		{
+			Header: "on property:a=1 && init",
+			Conditions: []string{"property:a=1", "init"},
+			Event: "init",
+		},
+		{Header: "on", Error: "on requires a trigger"},
+		{Header: "on boot init", Error: "expected &&, got \"init\""},
+		{Header: "on boot &&", Error: "expected trigger condition after &&"},
+		{Header: "on && boot", Error: "expected trigger condition, got &&"},
+		{Header: "on boot && init", Error: "multiple event triggers \"boot\" and \"init\""},
+		{Header: "on property:a", Error: "expected property:<name>=<value>"},
+		{Header: "on property:=1", Error: "empty property name"},
+		{Header: "on a=b", Error: "invalid event trigger"},
+	}
+	for _, c := range cases {
+		c := c
+		t.Run(c.Header, func(t *testing.T) {
+			f, err := Parse("init.rc", strings.NewReader(c.Header+"\n"), 0)
+			if c.Error != "" {
+				e, ok := err.(*token.Error)
+				if !ok || !strings.Contains(e.Msg, c.Error) {
+					t.Fatalf("expected error containing %q, got %v", c.Error, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected %s error", err)
+			}
+			trig := f.Triggers[0]
+			var conds []string
+			for _, cond := range trig.Conditions {
+				conds = append(conds, cond.String())
+			}
+			if !reflect.DeepEqual(conds, c.Conditions) {
+				t.Fatalf("expected %q conditions, got %q", c.Conditions, conds)
+			}
+			if event := trig.Event(); event != c.Event {
+				t.Fatalf("expected %q event, got %q", c.Event, event)
+			}
+		})
+	}
+}
+
+func TestConditionMatch(t *testing.T) {
+	c := Condition{Property: "a", Value: "1"}
+	if !c.Match("1") || c.Match("2") {
+		t.Fatal("unexpected match result for exact value")
+	}
+	c.Value = "*"
+	if !c.Match("1") || !c.Match("") {
+		t.Fatal("unexpected match result for wildcard value")
+	}
+}
+
+func TestTriggerCommands(t *testing.T) {
+	cases := []struct {
+		Command string
+		// Error is a substring of the expected error message, empty if no error is expected.
+		Error string
+	}{
+		{Command: "mkdir /data"},
+		{Command: "mkdir /data 0771 system system"},
+		{Command: "mkdir /data/${ro.dir} 0771"},
+		{Command: "mkdir ${ro.dir}"},
+		{Command: "mkdir data", Error: "argument 1: expected absolute path"},
+		{Command: "mkdir /data 999", Error: "argument 2: expected octal permissions"},
+		{Command: "mkdir /data 0771 a b c", Error: "argument 5: expected encryption=<action> or key=<key>"},
+		{Command: "mkdir /data/misc 01771 system misc encryption=Require"},
+		{Command: "mkdir /data/per_boot 0700 system system encryption=Require key=per_boot_ref"},
+		{Command: "mkdir /data 0771 a b encryption=Maybe", Error: "argument 5: expected one of None, Require, Attempt, DeleteIfNecessary"},
+		{Command: "mkdir /data 0771 a encryption=None", Error: "encryption must follow <mode> <owner> <group>"},
+		{Command: "mkdir /data 0771 a b key=ref key=ref", Error: "duplicate key option"},
+		{Command: "mkdir /data 0771 a b encryption=None key=ref x", Error: "expected at most 6 arguments, got 7"},
+		{Command: "mkdir /data ${ro.mode}"},
+		{Command: "chmod 0644 /data/x"},
+		{Command: "chmod rw /data/x", Error: "expected octal permissions"},
+		{Command: "chown system /data/x"},
+		{Command: "chown system system /data/x"},
+		{Command: "chown system system x", Error: "chown: expected absolute path, got \"x\", usage: chown"},
+		{Command: "write /proc/sys/kernel/panic 1"},
+		{Command: "write /proc/sys/kernel/panic", Error: "expected 2 arguments, got 1"},
+		{Command: "copy /a /b"},
+		{Command: "copy /a b", Error: "argument 2: expected absolute path"},
+		{Command: "symlink ../a /b"},
+		{Command: "rm /a"},
+		{Command: "rmdir /a"},
+		{Command: "setprop sys.a 1"},
+		{Command: "setprop sys.a=1 1", Error: "invalid property name"},
+		{Command: "start a"},
+		{Command: "stop", Error: "expected 1 arguments, got 0"},
+		{Command: "restart a"},
+		{Command: "restart --only-if-running a"},
+		{Command: "restart --always a", Error: "expected --only-if-running"},
+		{Command: "class_restart --only-enabled main"},
+		{Command: "class_restart --always main", Error: "expected --only-enabled"},
+		{Command: "installkey /data"},
+		{Command: "class_start_post_data hal"},
+		{Command: "perform_apex_config --bootstrap"},
+		{Command: "perform_apex_config --now", Error: "expected one of --bootstrap"},
+		{Command: "loglevel ${sys.init_log_level}"},
+		{Command: "loglevel 8", Error: "expected integer in range [0, 7]"},
+		{Command: "class_start main"},
+		{Command: "class_stop main"},
+		{Command: "exec -- /bin/true"},
+		{Command: "exec u:r:init:s0 root system -- /bin/true x"},
+		{Command: "exec /bin/true x", Error: "expected -- before command"},
+		{Command: "exec u:r:init:s0 --", Error: "expected command after --"},
+		{Command: "export PATH /bin"},
+		{Command: "export A=B x", Error: "invalid environment variable name"},
+		{Command: "hostname localhost"},
+		{Command: "wait /dev/block 5"},
+		{Command: "wait /dev/block soon", Error: "argument 2: expected integer"},
+		{Command: "trigger late-init"},
+		{Command: "trigger property:a=1", Error: "expected event"},
+		{Command: "load_system_props now", Error: "expected no arguments, got 1"},
+		{Command: "strat a", Error: "unknown command \"strat\", did you mean \"start\"?"},
+		{Command: "frobnicate", Error: "unknown command \"frobnicate\""},
+	}
+	for _, c := range cases {
+		c := c
+		t.Run(c.Command, func(t *testing.T) {
+			input := "on boot\n    " + c.Command + "\n"
+			_, err := Parse("init.rc", strings.NewReader(input), 0)
+			if c.Error == "" {
+				if err != nil {
+					t.Fatalf("unexpected %s error", err)
+				}
+				return
+			}
+			e, ok := err.(*token.Error)
+			if !ok {
+				t.Fatalf("expected *token.Error, got %v", err)
+			}
+			if e.Pos.Line != 1 || !strings.Contains(e.Msg, c.Error) {
+				t.Fatalf("expected error at line 2 containing %q, got %s", c.Error, e)
+			}
+		})
+	}
+}
+
+func TestCheckCommand(t *testing.T) {
+	cases := []struct {
+		Command Command
+		Error string
+	}{
+		{Command: Command{Name: "write", Args: []string{"/a", "x"}}},
+		{Command: Command{Name: "write"}, Error: "expected 2 arguments, got 0, usage: write <path> <content>"},
+		{Command: Command{Name: "loglevel", Args: []string{"8"}}, Error: "argument 1: expected integer in range [0, 7], got 8"},
+		{Command: Command{Name: "restart", Args: []string{"-f", "a"}}, Error: "expected --only-if-running, got \"-f\", usage: restart [ --only-if-running ] <service>"},
+		{Command: Command{Name: "strat"}, Error: "unknown command \"strat\""},
+	}
+	for _, c := range cases {
+		err := CheckCommand(c.Command)
+		if c.Error == "" && err != nil || c.Error != "" && (err == nil || err.Error() != c.Error) {
+			t.Errorf("%s: expected %q error, got %v", c.Command.Name, c.Error, err)
+		}
+	}
+}
+// aospInitRC is an excerpt of system/core/rootdir/init.rc of the Android Open Source Project.
+const aospInitRC = `
+on early-init
+    # Disable sysrq from keyboard
+    write /proc/sys/kernel/sysrq 0
+
+    # Android doesn't need kernel module autoloading, and it causes SELinux
+    # denials.  So disable it by setting modprobe to the empty string.
+    write /proc/sys/kernel/modprobe \n
+
+    # Set the security context of /adb_keys if present.
+    restorecon /adb_keys
+
+    # app mem cgroups, used by activity manager, lmkd and zygote
+    mkdir /dev/memcg/apps/ 0755 system system
+    # cgroup for system_server and surfaceflinger
+    mkdir /dev/memcg/system 0550 system system
+
+    start ueventd
+
+    # Run apexd-bootstrap so that APEXes that provide critical libraries
+    # become available.
+    exec_start apexd-bootstrap
+
+on init
+    sysclktz 0
+
+    # Mix device-specific information into the entropy pool
+    copy /proc/cmdline /dev/urandom
+
+    symlink /proc/self/fd/0 /dev/stdin
+    symlink /proc/self/fd/1 /dev/stdout
+    symlink /proc/self/fd/2 /dev/stderr
+
+    write /proc/sys/kernel/sched_child_runs_first 0
+    write /proc/sys/net/ipv4/ping_group_range "0 2147483647"
+
+    chown root system /sys/module/lowmemorykiller/parameters/adj
+    chmod 0664 /sys/module/lowmemorykiller/parameters/adj
+
+    # Start essential services.
+    start servicemanager
+    start hwservicemanager
+    start vndservicemanager
+
+on late-init
+    trigger early-fs
+    trigger fs
+    trigger post-fs
+    trigger late-fs
+    trigger post-fs-data
+    trigger zygote-start
+    trigger early-boot
+    trigger boot
+
+on post-fs-data
+    mark_post_data
+
+    # Start checkpoint before we touch data
+    exec - system system -- /system/bin/vdc checkpoint prepareCheckpoint
+
+    # We chown/chmod /data again so because mount is run as root + defaults
+    chown system system /data
+    chmod 0771 /data
+    # We restorecon /data in case the userdata partition has been reset.
+    restorecon /data
+
+    # Make sure we have the device encryption key.
+    installkey /data
+
+    # Start bootcharting as soon as possible after the data partition is
+    # mounted to collect more data.
+    mkdir /data/bootchart 0755 shell shell encryption=Require
+    bootchart start
+
+    # Make sure that apexd is started in the default namespace
+    enter_default_mount_ns
+
+    # /data/apex is now available. Start apexd to scan and activate APEXes.
+    mkdir /data/apex 0755 root system encryption=None
+    mkdir /data/apex/active 0755 root system
+    mkdir /data/app-staging 0751 system system encryption=DeleteIfNecessary
+    start apexd
+
+    mkdir /data/misc 01771 system misc encryption=Require
+    mkdir /data/misc/vold 0700 root root
+    mkdir /data/misc_ce 01771 system misc encryption=None
+    mkdir /data/user 0711 system system encryption=None
+    mkdir /data/media 0770 media_rw media_rw encryption=None
+
+    write /data/misc/recovery/ro.build.fingerprint ${ro.build.fingerprint}
+
+    # Set up per-user keys and user 0's directories
+    init_user0
+
+    # Set SELinux security contexts on upgrade or policy update.
+    restorecon --recursive --skip-ce /data
+
+    # Define and export *CLASSPATH variables
+    exec_start derive_classpath
+    load_exports /data/system/environ/classpath
+
+    start odsign
+    wait_for_prop odsign.key.done 1
+
+    exec - system system -- /system/bin/vdc keymaster earlyBootEnded
+
+    perform_apex_config
+
+    mkdir /data/media/obb 0770 media_rw media_rw encryption=Attempt
+
+    verity_update_state
+
+    # Re-generate linker config after apexes are activated
+    update_linker_config
+
+on boot
+    # basic network init
+    ifup lo
+    hostname localhost
+    domainname localdomain
+
+    write /proc/sys/vm/overcommit_memory 1
+    setprop net.tcp_def_init_rwnd 60
+
+    class_start core
+
+on nonencrypted
+    class_start main
+    class_start late_start
+
+on property:sys.init_log_level=*
+    loglevel ${sys.init_log_level}
+
+on property:vold.decrypt=trigger_restart_framework
+    # A/B update verifier that marks a successful boot.
+    exec_start update_verifier
+    class_start_post_data hal
+    class_start_post_data core
+    class_start main
+    class_start late_start
+    setprop service.bootanim.exit 0
+    start bootanim
+
+on property:vold.decrypt=trigger_shutdown_framework
+    class_reset late_start
+    class_reset main
+    class_reset_post_data core
+    class_reset_post_data hal
+
+on property:sys.boot_completed=1
+    bootchart stop
+    exec - system system -- /bin/rm -rf /data/per_boot
+    mkdir /data/per_boot 0700 system system encryption=Require key=per_boot_ref
+
+on userspace-reboot-requested
+  setprop sys.boot_completed ""
+  setprop dev.bootcomplete ""
+
+on userspace-reboot-fs-remount
+  start vold
+  exec - system system -- /system/bin/vdc checkpoint resetCheckpoint
+  umount /data_mirror/data_ce/null
+  remount_userdata
+  start bootanim
+
+service ueventd /system/bin/ueventd
+    class core
+    critical
+    seclabel u:r:ueventd:s0
+    shutdown critical
+
+service console /system/bin/sh
+    class core
+    console
+    disabled
+    user shell
+    group shell log readproc
+    seclabel u:r:shell:s0
+    setenv HOSTNAME console
+`
+
+func TestParseAOSP(t *testing.T) {
+	f, err := Parse("init.rc", strings.NewReader(aospInitRC), parser.AllErrors)
+	if err != nil {
+		t.Fatalf("unexpected errors:\n%s", err)
+	}
+	if len(f.Triggers) != 12 || len(f.Services) != 2 {
+		t.Fatalf("expected 12 triggers and 2 services, got %d and %d", len(f.Triggers), len(f.Services))
+	}
+}
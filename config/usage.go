@@ -0,0 +1,40 @@
+package config
+
+import (
+	"sort"
+)
+
+// sectionUsage are synopses of section headers.
+var sectionUsage = map[string]string{
+	"import": "import <path>",
+	"on": "on <trigger> [ && <trigger> ]*",
+	"service": "service <name> <pathname> [ <argument> ]*",
+}
+
+// sectionBodies maps section keywords to schemas of their body statements.
+var sectionBodies = map[string]map[string]optionSpec{
+	"on": triggerCommands,
+	"service": serviceOptions,
+}
+
+// Directives returns directives allowed in the body of the named section in sorted order.
+// Unknown sections and sections without body have no directives.
+func Directives(section string) []string {
+	var dirs []string
+	for dir := range sectionBodies[section] {
+		dirs = append(dirs, dir)
+	}
+	sort.Strings(dirs)
+	return dirs
+}
+
+// Usage returns synopsis of the directive in the named section, e.g. Usage("service", "user")
+// returns "user <username>".  Section headers are described as directives of their sections.
+func Usage(section, directive string) (string, bool) {
+	if section == directive {
+		usage, ok := sectionUsage[section]
+		return usage, ok
+	}
+	spec, ok := sectionBodies[section][directive]
+	return spec.Usage, ok
+}
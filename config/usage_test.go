@@ -0,0 +1,36 @@
+package config
+
+import (
+	"sort"
+	"testing"
+)
+
+func TestUsage(t *testing.T) {
+	cases := []struct {
+		Section, Directive string
+		Usage string
+	}{
+		{"service", "service", "service <name> <pathname> [ <argument> ]*"},
+		{"service", "user", "user <username>"},
+		{"on", "start", "start <service>"},
+		{"import", "import", "import <path>"},
+		{"on", "user", ""},
+		{"unknown", "start", ""},
+	}
+	for _, c := range cases {
+		usage, ok := Usage(c.Section, c.Directive)
+		if usage != c.Usage || ok != (c.Usage != "") {
+			t.Errorf("%s %s: expected %q, got %q", c.Section, c.Directive, c.Usage, usage)
+		}
+	}
+}
+
+func TestDirectives(t *testing.T) {
+	dirs := Directives("service")
+	if len(dirs) != len(serviceOptions) || !sort.StringsAreSorted(dirs) {
+		t.Errorf("unexpected service directives %q", dirs)
+	}
+	if dirs := Directives("import"); len(dirs) != 0 {
+		t.Errorf("unexpected import directives %q", dirs)
+	}
+}
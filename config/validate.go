@@ -0,0 +1,37 @@
+package config
+
+import (
+	"github.com/tie/x/config/token"
+)
+
+// Validate checks consistency of the loaded files, i.e. checks that are not local to a single
+// statement.  Currently it reports services defined more than once, unless redefinitions are
+// marked with the override option.  Errors are returned as token.ErrorList.
+func Validate(files []*File) error {
+	var errs token.ErrorList
+	services := map[string]*Service{}
+	for _, f := range files {
+		for _, svc := range f.Services {
+			prev, ok := services[svc.Name]
+			if ok && !svc.HasOption("override") {
+				e := token.Errorf(svc.Pos, token.InvalidStatement, "service %q redefined", svc.Name)
+				e.Related = &token.Related{Pos: prev.Pos, Msg: "previous definition"}
+				errs.Add(e)
+				continue
+			}
+			services[svc.Name] = svc
+		}
+	}
+	errs.Sort()
+	return errs.Err()
+}
+
+// HasOption reports whether the service has the named option.
+func (s *Service) HasOption(name string) bool {
+	for _, opt := range s.Options {
+		if opt.Name == name {
+			return true
+		}
+	}
+	return false
+}
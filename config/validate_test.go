@@ -0,0 +1,29 @@
+package config
+
+import (
+	"strings"
+	"testing"
+	"testing/fstest"
+
+	"github.com/tie/x/config/token"
+)
+
+func TestValidate(t *testing.T) {
+	fsys := fstest.MapFS{
+		"init.rc": {Data: []byte("import a.rc\nimport b.rc\nservice x /bin/x\n")},
+		"a.rc": {Data: []byte("service x /bin/x2\n")},
+		"b.rc": {Data: []byte("service x /bin/x3\n    override\n")},
+	}
+	files, err := (&Loader{FS: fsys}).Load("init.rc")
+	if err != nil {
+		t.Fatalf("unexpected %s error", err)
+	}
+	err = Validate(files)
+	errs, ok := err.(token.ErrorList)
+	if !ok || len(errs) != 1 {
+		t.Fatalf("expected single error, got %v", err)
+	}
+	if s := errs[0].Error(); !strings.HasPrefix(s, "a.rc:1:1: service \"x\" redefined, previous definition at init.rc:3:1") {
+		t.Fatalf("unexpected error %q", s)
+	}
+}
@@ -1 +1,3 @@
 module github.com/tie/x
+
+go 1.27.1
@@ -0,0 +1,110 @@
+package supervisor
+
+import (
+	"fmt"
+	"syscall"
+	"time"
+)
+
+const (
+	// DefaultCrashLimit is the number of crashes of a critical service allowed in the window.
+	DefaultCrashLimit = 4
+	// DefaultCrashWindow is the window of critical service crashes.
+	DefaultCrashWindow = 4 * time.Minute
+	// DefaultRebootTarget is the reboot target of critical services.
+	DefaultRebootTarget = "bootloader"
+)
+
+// RestartPolicy decides whether a service is restarted when it exits.  Exits requested with
+// Stop or Restart are not subject to the policy.
+type RestartPolicy int
+
+const (
+	// RestartAlways restarts the service on every exit.
+	RestartAlways RestartPolicy = iota
+	// RestartOnFailure restarts the service if it exits with non-zero status or is killed
+	// by a signal.
+	RestartOnFailure
+	// RestartNever does not restart the service, as for oneshot services.
+	RestartNever
+)
+
+var policyNames = [...]string{
+	RestartAlways: "always",
+	RestartOnFailure: "on-failure",
+	RestartNever: "never",
+}
+
+func (p RestartPolicy) String() string {
+	if p < 0 || int(p) >= len(policyNames) {
+		return fmt.Sprintf("RestartPolicy(%d)", int(p))
+	}
+	return policyNames[p]
+}
+
+// restarts reports whether the policy restarts a service that exited with the wait status.
+func (p RestartPolicy) restarts(ws syscall.WaitStatus) bool {
+	switch p {
+	case RestartAlways:
+		return true
+	case RestartOnFailure:
+		return !ws.Exited() || ws.ExitStatus() != 0
+	}
+	return false
+}
+
+// CrashLoop configures crash loop detection.  A crash is an exit that was not requested with
+// Stop or Restart.  When the service crashes more than Limit times in Window, it is stopped
+// and the supervisor OnCrashLoop action is called.
+type CrashLoop struct {
+	// Limit is the number of crashes allowed in the window.  Zero disables detection.
+	Limit int
+	Window time.Duration
+	// Target is the reboot target of critical services, for use by the action.
+	Target string
+}
+
+// crashLoop records the crash of the service at the time and reports whether the service is
+// in a crash loop.
+func (e *entry) crashLoop(now time.Time) bool {
+	cl := e.svc.CrashLoop
+	if cl.Limit <= 0 {
+		return false
+	}
+	// forget crashes out of the window
+	i := 0
+	for i < len(e.crashes) && now.Sub(e.crashes[i]) >= cl.Window {
+		i++
+	}
+	e.crashes = append(e.crashes[i:], now)
+	return len(e.crashes) > cl.Limit
+}
+
+// restartDelay returns the delay before the restart following n consecutive restarts.  The
+// delay starts at the restart period and doubles on each restart up to the max restart period.
+func (s *Service) restartDelay(n int) time.Duration {
+	delay := s.RestartPeriod
+	if delay == 0 {
+		delay = DefaultRestartPeriod
+	}
+	max := s.maxRestartPeriod()
+	for ; n > 0 && delay < max; n-- {
+		delay *= 2
+	}
+	if delay > max {
+		delay = max
+	}
+	return delay
+}
+
+// maxRestartPeriod returns the cap of the restart delay.
+func (s *Service) maxRestartPeriod() time.Duration {
+	if s.MaxRestartPeriod != 0 {
+		return s.MaxRestartPeriod
+	}
+	period := s.RestartPeriod
+	if period == 0 {
+		period = DefaultRestartPeriod
+	}
+	return DefaultMaxRestartFactor * period
+}
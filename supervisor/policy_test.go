@@ -0,0 +1,161 @@
+package supervisor
+
+import (
+	"testing"
+	"time"
+)
+
+func TestRestartDelay(t *testing.T) {
+	tests := []struct {
+		period, max time.Duration
+		want []time.Duration
+	}{
+		{0, 0, []time.Duration{
+			5 * time.Second,
+			10 * time.Second,
+			20 * time.Second,
+			40 * time.Second,
+			80 * time.Second,
+			80 * time.Second,
+		}},
+		{time.Second, 0, []time.Duration{
+			time.Second,
+			2 * time.Second,
+			4 * time.Second,
+			8 * time.Second,
+			16 * time.Second,
+			16 * time.Second,
+		}},
+		{time.Second, time.Second, []time.Duration{time.Second, time.Second}},
+		{time.Second, 5 * time.Second, []time.Duration{
+			time.Second,
+			2 * time.Second,
+			4 * time.Second,
+			5 * time.Second,
+			5 * time.Second,
+		}},
+		{0, time.Minute, []time.Duration{
+			5 * time.Second,
+			10 * time.Second,
+			20 * time.Second,
+			40 * time.Second,
+			time.Minute,
+		}},
+		{time.Minute, time.Second, []time.Duration{time.Second, time.Second}},
+	}
+	for _, test := range tests {
+		svc := &Service{RestartPeriod: test.period, MaxRestartPeriod: test.max}
+		for n, want := range test.want {
+			if got := svc.restartDelay(n); got != want {
+				t.Errorf("period %s max %s: expected %s delay after %d restarts, got %s", test.period, test.max, want, n, got)
+			}
+		}
+	}
+}
+
+func TestRestartPolicy(t *testing.T) {
+	tests := []struct {
+		policy RestartPolicy
+		script string
+		states []State
+	}{
+		{RestartAlways, "exit 0", []State{Starting, Running, Restarting}},
+		{RestartAlways, "exit 1", []State{Starting, Running, Restarting}},
+		{RestartOnFailure, "exit 0", []State{Starting, Running, Stopped}},
+		{RestartOnFailure, "exit 1", []State{Starting, Running, Restarting}},
+		{RestartOnFailure, "kill $$", []State{Starting, Running, Restarting}},
+		{RestartNever, "exit 1", []State{Starting, Running, Stopped}},
+	}
+	for _, test := range tests {
+		t.Run(test.policy.String()+"/"+test.script, func(t *testing.T) {
+			s, changes := newTestSupervisor(t, Options{})
+			svc := sh("a", test.script)
+			svc.Restart = test.policy
+			svc.RestartPeriod = time.Hour
+			s.Add(svc)
+			s.Start("a")
+			expectStates(t, changes, "a", test.states...)
+		})
+	}
+}
+
+func TestBackoff(t *testing.T) {
+	s, changes := newTestSupervisor(t, Options{})
+	svc := sh("a", "exit 1")
+	// the second restart is pending for 100ms
+	svc.RestartPeriod = 50 * time.Millisecond
+	svc.MaxRestartPeriod = time.Hour
+	s.Add(svc)
+	s.Start("a")
+	expectStates(t, changes, "a", Starting, Running, Restarting, Starting, Running, Restarting)
+	s.mu.Lock()
+	backoff := s.services["a"].backoff
+	s.mu.Unlock()
+	if backoff != 2 {
+		t.Fatalf("expected 2 consecutive restarts, got %d", backoff)
+	}
+	s.Stop("a")
+	s.mu.Lock()
+	backoff = s.services["a"].backoff
+	s.mu.Unlock()
+	if backoff != 0 {
+		t.Fatalf("expected backoff reset on stop, got %d", backoff)
+	}
+}
+
+func TestCrashLoop(t *testing.T) {
+	looped := make(chan *Service, 1)
+	s, changes := newTestSupervisor(t, Options{
+		OnCrashLoop: func(svc *Service) {
+			looped <- svc
+		},
+	})
+	svc := sh("a", "exit 1")
+	svc.RestartPeriod = time.Millisecond
+	svc.CrashLoop = CrashLoop{Limit: 2, Window: time.Minute, Target: "recovery"}
+	s.Add(svc)
+	s.Start("a")
+	expectStates(t, changes, "a",
+		Starting, Running, Restarting,
+		Starting, Running, Restarting,
+		Starting, Running, Stopped,
+	)
+	select {
+	case got := <-looped:
+		if got != svc {
+			t.Fatalf("expected action for %s, got %s", svc.Name, got.Name)
+		}
+	case <-time.After(testTimeout):
+		t.Fatal("timed out waiting for crash loop action")
+	}
+	st, _ := s.Status("a")
+	if st.State != Stopped || st.Starts != 3 {
+		t.Fatalf("unexpected %+v status", st)
+	}
+}
+
+func TestCrashLoopWindow(t *testing.T) {
+	e := &entry{svc: &Service{CrashLoop: CrashLoop{Limit: 2, Window: time.Minute}}}
+	now := time.Now()
+	times := []struct {
+		at time.Duration
+		loop bool
+	}{
+		{0, false},
+		{30 * time.Second, false},
+		// the first crash is out of the window
+		{60 * time.Second, false},
+		{61 * time.Second, true},
+	}
+	for _, tt := range times {
+		if got := e.crashLoop(now.Add(tt.at)); got != tt.loop {
+			t.Fatalf("expected %v crash loop at %s, got %v", tt.loop, tt.at, got)
+		}
+	}
+	e.svc.CrashLoop.Limit = 0
+	for i := 0; i < 10; i++ {
+		if e.crashLoop(now) {
+			t.Fatal("unexpected crash loop with detection disabled")
+		}
+	}
+}
@@ -0,0 +1,88 @@
+//go:build linux
+
+package supervisor
+
+import (
+	"os"
+	"os/signal"
+	"syscall"
+)
+
+// prSetChildSubreaper is the prctl option marking the process as a child subreaper.
+const prSetChildSubreaper = 36
+
+// Reaper collects exit statuses of all child processes on SIGCHLD, including orphans
+// reparented to the process.  While the reaper runs, no other code in the process may wait
+// for child processes, so supervisors must use the Reaper option.
+type Reaper struct {
+	exited func(pid int, ws syscall.WaitStatus)
+	sigs chan os.Signal
+	done chan struct{}
+}
+
+// NewReaper starts reaping child processes and calls exited with their exit statuses from
+// the reaper goroutine.  Unless the process is PID 1, it becomes a child subreaper, so that
+// orphaned descendants are reparented to it instead of PID 1.
+//
+// A typical use dispatches exits to a supervisor:
+//
+//	s := supervisor.New(supervisor.Options{Reaper: true})
+//	r, err := supervisor.NewReaper(func(pid int, ws syscall.WaitStatus) {
+//		s.Exited(pid, ws)
+//	})
+func NewReaper(exited func(pid int, ws syscall.WaitStatus)) (*Reaper, error) {
+	if os.Getpid() != 1 {
+		if err := setChildSubreaper(); err != nil {
+			return nil, err
+		}
+	}
+	r := &Reaper{
+		exited: exited,
+		sigs: make(chan os.Signal, 1),
+		done: make(chan struct{}),
+	}
+	signal.Notify(r.sigs, syscall.SIGCHLD)
+	go r.run()
+	return r, nil
+}
+
+// Close stops reaping child processes.  The process remains a child subreaper.
+func (r *Reaper) Close() {
+	signal.Stop(r.sigs)
+	close(r.sigs)
+	<-r.done
+}
+
+func (r *Reaper) run() {
+	defer close(r.done)
+	// children may have exited before the signal handler was installed
+	r.reap()
+	for range r.sigs {
+		r.reap()
+	}
+}
+
+// reap reaps all exited child processes.  Signals are coalesced, so a single SIGCHLD may
+// stand for many exits.
+func (r *Reaper) reap() {
+	for {
+		var ws syscall.WaitStatus
+		pid, err := syscall.Wait4(-1, &ws, syscall.WNOHANG, nil)
+		if err == syscall.EINTR {
+			continue
+		}
+		// zero pid means no more exited children, and ECHILD means no children at all
+		if err != nil || pid <= 0 {
+			return
+		}
+		r.exited(pid, ws)
+	}
+}
+
+func setChildSubreaper() error {
+	_, _, errno := syscall.RawSyscall(syscall.SYS_PRCTL, prSetChildSubreaper, 1, 0)
+	if errno != 0 {
+		return os.NewSyscallError("prctl", errno)
+	}
+	return nil
+}
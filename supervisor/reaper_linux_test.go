@@ -0,0 +1,83 @@
+//go:build linux
+
+package supervisor
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+)
+
+type exit struct {
+	pid int
+	ws syscall.WaitStatus
+}
+
+func TestReaper(t *testing.T) {
+	// the reaper waits for any child of the process, so the test runs in a child process to
+	// not reap processes of other tests
+	if os.Getenv("SUPERVISOR_REAPER_TEST") == "" {
+		cmd := exec.Command(os.Args[0], "-test.run=^TestReaper$")
+		cmd.Env = append(os.Environ(), "SUPERVISOR_REAPER_TEST=1")
+		if out, err := cmd.CombinedOutput(); err != nil {
+			t.Fatalf("reaper test failed: %v\n%s", err, out)
+		}
+		return
+	}
+	s, changes := newTestSupervisor(t, Options{Reaper: true})
+	orphans := make(chan exit, 100)
+	r, err := NewReaper(func(pid int, ws syscall.WaitStatus) {
+		if !s.Exited(pid, ws) {
+			orphans <- exit{pid, ws}
+		}
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+
+	// the background subshell outlives the service and is reparented to the test process
+	out := filepath.Join(t.TempDir(), "pid")
+	svc := sh("a", "(sleep 0.1; exit 7) & echo $! > "+out+"; exit 3")
+	svc.Restart = RestartNever
+	s.Add(svc)
+	s.Start("a")
+	expectStates(t, changes, "a", Starting, Running, Stopped)
+	st, _ := s.Status("a")
+	if !st.Exit.Exited() || st.Exit.ExitStatus() != 3 {
+		t.Fatalf("expected exit status 3, got %v", st.Exit)
+	}
+	data, err := os.ReadFile(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pid, _ := strconv.Atoi(strings.TrimSpace(string(data)))
+	select {
+	case o := <-orphans:
+		if o.pid != pid || !o.ws.Exited() || o.ws.ExitStatus() != 7 {
+			t.Fatalf("expected orphan %d with exit status 7, got %d with %v", pid, o.pid, o.ws)
+		}
+	case <-time.After(testTimeout):
+		t.Fatalf("timed out waiting for orphan %d", pid)
+	}
+
+	s.Add(sh("b", "exec sleep 60"))
+	s.Start("b")
+	expectStates(t, changes, "b", Starting, Running)
+	s.Stop("b")
+	expectStates(t, changes, "b", Stopping, Stopped)
+	st, _ = s.Status("b")
+	if !st.Exit.Signaled() || st.Exit.Signal() != syscall.SIGTERM {
+		t.Fatalf("expected SIGTERM exit, got %v", st.Exit)
+	}
+
+	ws, err := s.Run(sh("c", "exit 4"))
+	if err != nil || !ws.Exited() || ws.ExitStatus() != 4 {
+		t.Fatalf("expected exit status 4, got %v, %v", ws, err)
+	}
+}
@@ -0,0 +1,100 @@
+package supervisor
+
+import (
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/tie/x/config"
+)
+
+// DefaultRestartPeriod is the delay before restarting a service that exited.
+const DefaultRestartPeriod = 5 * time.Second
+
+// DefaultMaxRestartFactor is the multiple of the restart period that caps the restart delay
+// of services without MaxRestartPeriod.
+const DefaultMaxRestartFactor = 16
+
+// Service is a definition of a supervised service.
+type Service struct {
+	Name string
+	// Path is the executable, and Args are arguments following it.
+	Path string
+	Args []string
+	// Env are environment variables in key=value form added to the supervisor environment.
+	Env []string
+	// Credential sets user and groups of the process.  Nil means those of the supervisor.
+	Credential *syscall.Credential
+	// Classes are names of service classes, used to start and stop services in groups.
+	Classes []string
+	// Disabled services are not started with their class.
+	Disabled bool
+	// Restart is the policy of restarting the service when it exits.
+	Restart RestartPolicy
+	// RestartPeriod is the delay before restarting the service when it exits.  Zero means
+	// DefaultRestartPeriod.
+	RestartPeriod time.Duration
+	// MaxRestartPeriod caps the delay that doubles on each consecutive restart.  Zero means
+	// DefaultMaxRestartFactor times the restart period, and RestartPeriod disables backoff.
+	// A process running for at least MaxRestartPeriod resets the delay.
+	MaxRestartPeriod time.Duration
+	// CrashLoop configures crash loop detection.
+	CrashLoop CrashLoop
+}
+
+// FromConfig returns a definition of the service defined in a config file.  Options that
+// are not supported by the supervisor are ignored.  The service must be checked by the
+// config parser, so that option arguments are valid.
+func FromConfig(svc *config.Service) *Service {
+	s := &Service{
+		Name: svc.Name,
+		Path: svc.Path,
+		Args: svc.Args,
+	}
+	for _, opt := range svc.Options {
+		switch opt.Name {
+		case "class":
+			s.Classes = append(s.Classes, opt.Args...)
+		case "disabled":
+			s.Disabled = true
+		case "oneshot":
+			s.Restart = RestartNever
+		case "critical":
+			s.CrashLoop = CrashLoop{
+				Limit: DefaultCrashLimit,
+				Window: DefaultCrashWindow,
+				Target: DefaultRebootTarget,
+			}
+			for _, arg := range opt.Args {
+				i := strings.IndexByte(arg, '=')
+				switch key, val := arg[:i], arg[i+1:]; key {
+				case "window":
+					minutes, _ := strconv.Atoi(val)
+					s.CrashLoop.Window = time.Duration(minutes) * time.Minute
+				case "target":
+					s.CrashLoop.Target = val
+				}
+			}
+		case "setenv":
+			s.Env = append(s.Env, opt.Args[0]+"="+opt.Args[1])
+		case "restart_period":
+			seconds, _ := strconv.Atoi(opt.Args[0])
+			s.RestartPeriod = time.Duration(seconds) * time.Second
+		}
+	}
+	if len(s.Classes) == 0 {
+		s.Classes = []string{"default"}
+	}
+	return s
+}
+
+// HasClass reports whether the service belongs to the class.
+func (s *Service) HasClass(class string) bool {
+	for _, c := range s.Classes {
+		if c == class {
+			return true
+		}
+	}
+	return false
+}
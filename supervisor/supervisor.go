@@ -0,0 +1,440 @@
+// Package supervisor runs services and keeps track of their state.
+//
+// Each service goes through the following states:
+//
+//	Stopped -> Starting -> Running -> Stopping -> Stopped
+//	                          |                      ^
+//	                          v                      |
+//	                      Restarting ----------------+
+//
+// A running service that exits is restarted according to its restart policy, with the delay
+// growing on consecutive restarts.  A service that crashes too often is stopped, and the
+// supervisor calls the crash loop action, e.g. to reboot on crashes of critical services.
+// Each service runs in its own process group.  Stopping a service sends SIGTERM to the group,
+// and SIGKILL if the service does not exit in time.
+//
+// By default the supervisor waits for service processes itself.  When running as PID 1 or
+// a container entrypoint, a Reaper should collect all children instead, including orphans.
+package supervisor
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"sort"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// DefaultStopTimeout is the time given to a service to exit after SIGTERM.
+const DefaultStopTimeout = 5 * time.Second
+
+// State is the state of a service.
+type State int
+
+const (
+	// Stopped services have no process.
+	Stopped State = iota
+	// Starting services are being started.
+	Starting
+	// Running services have a process.
+	Running
+	// Restarting services have exited and are waiting for the restart period to pass.
+	Restarting
+	// Stopping services were asked to exit and are waiting for the process to exit.
+	Stopping
+)
+
+var stateNames = [...]string{
+	Stopped: "stopped",
+	Starting: "starting",
+	Running: "running",
+	Restarting: "restarting",
+	Stopping: "stopping",
+}
+
+func (s State) String() string {
+	if s < 0 || int(s) >= len(stateNames) {
+		return fmt.Sprintf("State(%d)", int(s))
+	}
+	return stateNames[s]
+}
+
+// Status is a snapshot of a service state.
+type Status struct {
+	State State
+	// Pid is the process ID of a running or stopping service.
+	Pid int
+	// Starts is the number of times the service process was started.
+	Starts int
+	// Exit is the wait status of the last exited process.  It is valid if the service was
+	// started and is not running.
+	Exit syscall.WaitStatus
+}
+
+// Options configure the supervisor.
+type Options struct {
+	// Env is the environment of all services.  Nil means the supervisor environment.
+	Env []string
+	// StopTimeout is the time given to a service to exit after SIGTERM.  Zero means
+	// DefaultStopTimeout.
+	StopTimeout time.Duration
+	// OnChange is called on every state change with the supervisor lock held, so it must
+	// not call supervisor methods.
+	OnChange func(name string, state State)
+	// OnCrashLoop is called without the supervisor lock when a service is stopped on a
+	// crash loop.  Nil means no action.
+	OnCrashLoop func(svc *Service)
+	// Reaper disables waiting for service processes by the supervisor.  Instead, exit
+	// statuses must be passed to Exited, usually by a Reaper.
+	Reaper bool
+}
+
+// Supervisor runs services.  It is safe for concurrent use.
+type Supervisor struct {
+	opts Options
+	mu sync.Mutex
+	services map[string]*entry
+	// pids are running and stopping services by process ID
+	pids map[int]*entry
+}
+
+// entry is the state of a single service.
+type entry struct {
+	svc *Service
+	status Status
+	// cmd is the process of a running or stopping service
+	cmd *exec.Cmd
+	// restart is set when the service should be started again after it stops
+	restart bool
+	// timer is the pending restart or kill
+	timer *time.Timer
+	// started is the start time of the process
+	started time.Time
+	// backoff is the number of consecutive restarts
+	backoff int
+	// crashes are times of crashes in the crash loop window
+	crashes []time.Time
+	// done receives the exit status of processes started by Run
+	done chan syscall.WaitStatus
+}
+
+// New returns a supervisor without services.
+func New(opts Options) *Supervisor {
+	if opts.Env == nil {
+		opts.Env = os.Environ()
+	}
+	if opts.StopTimeout == 0 {
+		opts.StopTimeout = DefaultStopTimeout
+	}
+	return &Supervisor{
+		opts: opts,
+		services: map[string]*entry{},
+		pids: map[int]*entry{},
+	}
+}
+
+// Add adds the service in stopped state.  Service names must be unique.
+func (s *Supervisor) Add(svc *Service) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.services[svc.Name]; ok {
+		return fmt.Errorf("service %s already exists", svc.Name)
+	}
+	s.services[svc.Name] = &entry{svc: svc}
+	return nil
+}
+
+// Services returns names of all services in sorted order.
+func (s *Supervisor) Services() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	names := make([]string, 0, len(s.services))
+	for name := range s.services {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Service returns the definition of the named service.
+func (s *Supervisor) Service(name string) (*Service, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	e, err := s.entry(name)
+	if err != nil {
+		return nil, err
+	}
+	return e.svc, nil
+}
+
+// Status returns the status of the named service.
+func (s *Supervisor) Status(name string) (Status, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	e, err := s.entry(name)
+	if err != nil {
+		return Status{}, err
+	}
+	return e.status, nil
+}
+
+// Start starts the named service.  Starting a running service does nothing, and starting a
+// stopping service restarts it once it exits.
+func (s *Supervisor) Start(name string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	e, err := s.entry(name)
+	if err != nil {
+		return err
+	}
+	switch e.status.State {
+	case Stopping:
+		e.restart = true
+		return nil
+	case Stopped, Restarting:
+		return s.start(e)
+	}
+	return nil
+}
+
+// Stop stops the named service.  It returns before the service exits.
+func (s *Supervisor) Stop(name string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	e, err := s.entry(name)
+	if err != nil {
+		return err
+	}
+	e.restart = false
+	e.backoff = 0
+	switch e.status.State {
+	case Running:
+		s.stop(e)
+	case Restarting:
+		e.timer.Stop()
+		s.setState(e, Stopped)
+	}
+	return nil
+}
+
+// Restart stops the named service if it is running and starts it again.
+func (s *Supervisor) Restart(name string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	e, err := s.entry(name)
+	if err != nil {
+		return err
+	}
+	switch e.status.State {
+	case Running:
+		e.restart = true
+		s.stop(e)
+	case Stopping:
+		e.restart = true
+	case Stopped, Restarting:
+		return s.start(e)
+	}
+	return nil
+}
+
+// Run runs the service process to completion and returns its wait status.  The service is
+// not added to the supervisor, and its restart policy is ignored.
+func (s *Supervisor) Run(svc *Service) (syscall.WaitStatus, error) {
+	s.mu.Lock()
+	cmd := s.command(svc)
+	if err := cmd.Start(); err != nil {
+		s.mu.Unlock()
+		return 0, fmt.Errorf("run %s: %w", svc.Name, err)
+	}
+	done := make(chan syscall.WaitStatus, 1)
+	s.pids[cmd.Process.Pid] = &entry{svc: svc, cmd: cmd, done: done}
+	s.mu.Unlock()
+	if !s.opts.Reaper {
+		go s.wait(cmd)
+	}
+	return <-done, nil
+}
+
+// Setenv sets the environment variable of services started afterwards.
+func (s *Supervisor) Setenv(name, value string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	env := make([]string, 0, len(s.opts.Env)+1)
+	for _, kv := range s.opts.Env {
+		if !strings.HasPrefix(kv, name+"=") {
+			env = append(env, kv)
+		}
+	}
+	s.opts.Env = append(env, name+"="+value)
+}
+
+// StopAll stops all services.
+func (s *Supervisor) StopAll() {
+	for _, name := range s.Services() {
+		s.Stop(name)
+	}
+}
+
+func (s *Supervisor) entry(name string) (*entry, error) {
+	e, ok := s.services[name]
+	if !ok {
+		return nil, fmt.Errorf("service %s not found", name)
+	}
+	return e, nil
+}
+
+func (s *Supervisor) setState(e *entry, state State) {
+	e.status.State = state
+	if s.opts.OnChange != nil {
+		s.opts.OnChange(e.svc.Name, state)
+	}
+}
+
+// start starts the service process.  On failure the service is stopped.
+func (s *Supervisor) start(e *entry) error {
+	if e.timer != nil {
+		e.timer.Stop()
+		e.timer = nil
+	}
+	e.restart = false
+	s.setState(e, Starting)
+	cmd := s.command(e.svc)
+	if err := cmd.Start(); err != nil {
+		s.setState(e, Stopped)
+		return fmt.Errorf("start service %s: %w", e.svc.Name, err)
+	}
+	e.cmd = cmd
+	e.started = time.Now()
+	e.status.Pid = cmd.Process.Pid
+	e.status.Starts++
+	s.pids[e.status.Pid] = e
+	s.setState(e, Running)
+	if !s.opts.Reaper {
+		go s.wait(cmd)
+	}
+	return nil
+}
+
+// command returns the command starting the service process.
+func (s *Supervisor) command(svc *Service) *exec.Cmd {
+	cmd := exec.Command(svc.Path, svc.Args...)
+	cmd.Env = append(s.opts.Env[:len(s.opts.Env):len(s.opts.Env)], svc.Env...)
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	// signals of the supervisor process group are not delivered to services
+	cmd.SysProcAttr = &syscall.SysProcAttr{
+		Setpgid: true,
+		Credential: svc.Credential,
+	}
+	return cmd
+}
+
+// stop sends SIGTERM to the service process group, and SIGKILL after the stop timeout.
+func (s *Supervisor) stop(e *entry) {
+	s.setState(e, Stopping)
+	cmd := e.cmd
+	syscall.Kill(-cmd.Process.Pid, syscall.SIGTERM)
+	e.timer = time.AfterFunc(s.opts.StopTimeout, func() {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		if e.cmd == cmd {
+			syscall.Kill(-cmd.Process.Pid, syscall.SIGKILL)
+		}
+	})
+}
+
+// LostStatus is the wait status of service processes that were reaped elsewhere, e.g. by
+// a reaper, so that their exit status is unknown.  It is a failure exit with status 255.
+const LostStatus = syscall.WaitStatus(255 << 8)
+
+// wait waits for the process to exit and updates the service state.
+func (s *Supervisor) wait(cmd *exec.Cmd) {
+	// exit errors have the process state, other errors mean the process was not waited for
+	if err := cmd.Wait(); err != nil && cmd.ProcessState == nil {
+		s.Exited(cmd.Process.Pid, LostStatus)
+		return
+	}
+	s.Exited(cmd.Process.Pid, cmd.ProcessState.Sys().(syscall.WaitStatus))
+}
+
+// Exited handles exit of the process with the wait status.  It reports whether the process
+// belongs to a service.
+func (s *Supervisor) Exited(pid int, ws syscall.WaitStatus) bool {
+	s.mu.Lock()
+	e, ok := s.pids[pid]
+	if !ok {
+		s.mu.Unlock()
+		return false
+	}
+	delete(s.pids, pid)
+	if e.done != nil {
+		if s.opts.Reaper {
+			e.cmd.Process.Release()
+		}
+		e.done <- ws
+		s.mu.Unlock()
+		return true
+	}
+	action := s.exited(e, ws)
+	s.mu.Unlock()
+	if action != nil {
+		action()
+	}
+	return true
+}
+
+// exited handles exit of the service process.  It returns the action to call after releasing
+// the lock, or nil.
+func (s *Supervisor) exited(e *entry, ws syscall.WaitStatus) func() {
+	if e.timer != nil {
+		e.timer.Stop()
+		e.timer = nil
+	}
+	if s.opts.Reaper {
+		// the process is reaped, but os.Process may hold its resources
+		e.cmd.Process.Release()
+	}
+	e.cmd = nil
+	e.status.Pid = 0
+	e.status.Exit = ws
+	if e.restart {
+		s.start(e)
+		return nil
+	}
+	if e.status.State == Stopping {
+		s.setState(e, Stopped)
+		return nil
+	}
+	now := time.Now()
+	if now.Sub(e.started) >= e.svc.maxRestartPeriod() {
+		e.backoff = 0
+	}
+	if e.crashLoop(now) {
+		e.crashes = nil
+		e.backoff = 0
+		s.setState(e, Stopped)
+		if s.opts.OnCrashLoop == nil {
+			return nil
+		}
+		svc, action := e.svc, s.opts.OnCrashLoop
+		return func() { action(svc) }
+	}
+	if !e.svc.Restart.restarts(ws) {
+		s.setState(e, Stopped)
+		return nil
+	}
+	delay := e.svc.restartDelay(e.backoff)
+	e.backoff++
+	s.setState(e, Restarting)
+	e.timer = time.AfterFunc(delay, func() {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		if e.status.State == Restarting {
+			s.start(e)
+		}
+	})
+	return nil
+}
@@ -0,0 +1,359 @@
+package supervisor
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/tie/x/config"
+	"github.com/tie/x/config/parser"
+)
+
+// testTimeout bounds waiting for a state change.
+const testTimeout = 5 * time.Second
+
+type change struct {
+	name string
+	state State
+}
+
+// newTestSupervisor returns a supervisor that reports state changes to the channel.
+func newTestSupervisor(t *testing.T, opts Options) (*Supervisor, <-chan change) {
+	changes := make(chan change, 100)
+	opts.OnChange = func(name string, state State) {
+		changes <- change{name, state}
+	}
+	s := New(opts)
+	// services are stopped and their processes are waited for, so that they are not left
+	// to other tests
+	t.Cleanup(func() {
+		for _, name := range s.Services() {
+			s.Stop(name)
+			s.mu.Lock()
+			if e := s.services[name]; e.cmd != nil {
+				syscall.Kill(-e.cmd.Process.Pid, syscall.SIGKILL)
+			}
+			s.mu.Unlock()
+		}
+		deadline := time.Now().Add(testTimeout)
+		for _, name := range s.Services() {
+			for {
+				st, _ := s.Status(name)
+				if st.State == Stopped {
+					break
+				}
+				if time.Now().After(deadline) {
+					t.Errorf("service %s is %s after the test", name, st.State)
+					break
+				}
+				time.Sleep(10 * time.Millisecond)
+			}
+		}
+	})
+	return s, changes
+}
+
+// sh returns a service running the shell script.
+func sh(name, script string) *Service {
+	return &Service{
+		Name: name,
+		Path: "/bin/sh",
+		Args: []string{"-c", script},
+	}
+}
+
+// expectStates waits for the sequence of state changes.
+func expectStates(t *testing.T, changes <-chan change, name string, states ...State) {
+	t.Helper()
+	for _, want := range states {
+		select {
+		case c := <-changes:
+			if c.name != name || c.state != want {
+				t.Fatalf("expected %s %s, got %s %s", name, want, c.name, c.state)
+			}
+		case <-time.After(testTimeout):
+			t.Fatalf("timed out waiting for %s %s", name, want)
+		}
+	}
+}
+
+func TestOneshot(t *testing.T) {
+	s, changes := newTestSupervisor(t, Options{})
+	svc := sh("a", "exit 3")
+	svc.Restart = RestartNever
+	if err := s.Add(svc); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Start("a"); err != nil {
+		t.Fatal(err)
+	}
+	expectStates(t, changes, "a", Starting, Running, Stopped)
+	st, _ := s.Status("a")
+	if st.Pid != 0 || st.Starts != 1 {
+		t.Fatalf("unexpected %+v status", st)
+	}
+	if !st.Exit.Exited() || st.Exit.ExitStatus() != 3 {
+		t.Fatalf("expected exit code 3, got %v", st.Exit)
+	}
+}
+
+func TestStop(t *testing.T) {
+	s, changes := newTestSupervisor(t, Options{})
+	s.Add(sh("a", "exec sleep 60"))
+	s.Start("a")
+	expectStates(t, changes, "a", Starting, Running)
+	st, _ := s.Status("a")
+	if st.State != Running || st.Pid == 0 {
+		t.Fatalf("unexpected %+v status", st)
+	}
+	if err := s.Stop("a"); err != nil {
+		t.Fatal(err)
+	}
+	expectStates(t, changes, "a", Stopping, Stopped)
+	st, _ = s.Status("a")
+	if !st.Exit.Signaled() || st.Exit.Signal() != syscall.SIGTERM {
+		t.Fatalf("expected SIGTERM exit, got %v", st.Exit)
+	}
+}
+
+func TestStopTimeout(t *testing.T) {
+	s, changes := newTestSupervisor(t, Options{StopTimeout: 50 * time.Millisecond})
+	dir := t.TempDir()
+	ready := filepath.Join(dir, "ready")
+	s.Add(sh("a", "trap '' TERM; touch "+ready+"; while :; do sleep 1; done"))
+	s.Start("a")
+	expectStates(t, changes, "a", Starting, Running)
+	waitFile(t, ready)
+	s.Stop("a")
+	expectStates(t, changes, "a", Stopping, Stopped)
+	st, _ := s.Status("a")
+	if !st.Exit.Signaled() || st.Exit.Signal() != syscall.SIGKILL {
+		t.Fatalf("expected SIGKILL exit, got %v", st.Exit)
+	}
+}
+
+func TestRestart(t *testing.T) {
+	s, changes := newTestSupervisor(t, Options{})
+	s.Add(sh("a", "exec sleep 60"))
+	s.Start("a")
+	expectStates(t, changes, "a", Starting, Running)
+	before, _ := s.Status("a")
+	if err := s.Restart("a"); err != nil {
+		t.Fatal(err)
+	}
+	expectStates(t, changes, "a", Stopping, Starting, Running)
+	after, _ := s.Status("a")
+	if after.Starts != 2 || after.Pid == before.Pid {
+		t.Fatalf("unexpected %+v status after %+v", after, before)
+	}
+	s.Stop("a")
+	expectStates(t, changes, "a", Stopping, Stopped)
+}
+
+func TestRestartOnExit(t *testing.T) {
+	s, changes := newTestSupervisor(t, Options{})
+	// the service exits on the first run only
+	marker := filepath.Join(t.TempDir(), "marker")
+	svc := sh("a", "test -e "+marker+" && exec sleep 60; touch "+marker+"; exit 1")
+	svc.RestartPeriod = 10 * time.Millisecond
+	s.Add(svc)
+	s.Start("a")
+	expectStates(t, changes, "a", Starting, Running, Restarting, Starting, Running)
+	st, _ := s.Status("a")
+	if st.Starts != 2 || st.Exit.ExitStatus() != 1 {
+		t.Fatalf("unexpected %+v status", st)
+	}
+	s.Stop("a")
+	expectStates(t, changes, "a", Stopping, Stopped)
+}
+
+func TestStopRestarting(t *testing.T) {
+	s, changes := newTestSupervisor(t, Options{})
+	svc := sh("a", "exit 1")
+	svc.RestartPeriod = time.Hour
+	s.Add(svc)
+	s.Start("a")
+	expectStates(t, changes, "a", Starting, Running, Restarting)
+	s.Stop("a")
+	expectStates(t, changes, "a", Stopped)
+}
+
+func TestStartStopping(t *testing.T) {
+	s, changes := newTestSupervisor(t, Options{})
+	s.Add(sh("a", "exec sleep 60"))
+	s.Start("a")
+	expectStates(t, changes, "a", Starting, Running)
+	s.Stop("a")
+	s.Start("a")
+	expectStates(t, changes, "a", Stopping, Starting, Running)
+	s.Stop("a")
+	expectStates(t, changes, "a", Stopping, Stopped)
+}
+
+func TestEnv(t *testing.T) {
+	out := filepath.Join(t.TempDir(), "out")
+	s, changes := newTestSupervisor(t, Options{Env: []string{"A=1", "B=2"}})
+	svc := sh("a", `echo "$A $B $C" > "$OUT"`)
+	svc.Restart = RestartNever
+	svc.Env = []string{"B=3", "C=4", "OUT=" + out}
+	s.Add(svc)
+	s.Start("a")
+	expectStates(t, changes, "a", Starting, Running, Stopped)
+	data, err := os.ReadFile(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := string(data); got != "1 3 4\n" {
+		t.Fatalf("expected %q output, got %q", "1 3 4\n", got)
+	}
+}
+
+func TestSetenv(t *testing.T) {
+	out := filepath.Join(t.TempDir(), "out")
+	s, _ := newTestSupervisor(t, Options{Env: []string{"A=1", "B=2"}})
+	s.Setenv("A", "3")
+	s.Setenv("C", "4")
+	ws, err := s.Run(sh("a", `echo "$A $B $C" > `+out))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !ws.Exited() || ws.ExitStatus() != 0 {
+		t.Fatalf("unexpected %v status", ws)
+	}
+	data, _ := os.ReadFile(out)
+	if got := string(data); got != "3 2 4\n" {
+		t.Fatalf("expected %q output, got %q", "3 2 4\n", got)
+	}
+}
+
+func TestRun(t *testing.T) {
+	s, changes := newTestSupervisor(t, Options{})
+	ws, err := s.Run(sh("a", "exit 5"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !ws.Exited() || ws.ExitStatus() != 5 {
+		t.Fatalf("expected exit status 5, got %v", ws)
+	}
+	if _, err := s.Run(&Service{Name: "b", Path: "/nonexistent"}); err == nil {
+		t.Fatal("expected error")
+	}
+	if len(s.Services()) != 0 || len(changes) != 0 {
+		t.Fatal("unexpected services")
+	}
+}
+
+func TestWaitLost(t *testing.T) {
+	// without a reaper goroutine, the test reaps the process before wait does
+	s, changes := newTestSupervisor(t, Options{Reaper: true})
+	svc := sh("a", "exit 3")
+	svc.Restart = RestartNever
+	s.Add(svc)
+	s.Start("a")
+	expectStates(t, changes, "a", Starting, Running)
+	s.mu.Lock()
+	cmd := s.services["a"].cmd
+	s.mu.Unlock()
+	var ws syscall.WaitStatus
+	if _, err := syscall.Wait4(cmd.Process.Pid, &ws, 0, nil); err != nil {
+		t.Fatal(err)
+	}
+	s.wait(cmd)
+	expectStates(t, changes, "a", Stopped)
+	if st, _ := s.Status("a"); st.Exit != LostStatus || !st.Exit.Exited() || st.Exit.ExitStatus() != 255 {
+		t.Fatalf("expected lost status, got %v", st.Exit)
+	}
+}
+
+func TestStartError(t *testing.T) {
+	s, changes := newTestSupervisor(t, Options{})
+	s.Add(&Service{Name: "a", Path: "/nonexistent"})
+	if err := s.Start("a"); err == nil {
+		t.Fatal("expected error")
+	}
+	expectStates(t, changes, "a", Starting, Stopped)
+	if err := s.Start("b"); err == nil {
+		t.Fatal("expected error for unknown service")
+	}
+	if err := s.Add(&Service{Name: "a"}); err == nil {
+		t.Fatal("expected error for duplicate service")
+	}
+}
+
+func TestFromConfig(t *testing.T) {
+	src := strings.Join([]string{
+		"service a /bin/a -x y",
+		"    class core main",
+		"    oneshot",
+		"    disabled",
+		"    setenv FOO bar",
+		"    restart_period 7",
+		"service b /bin/b",
+		"    critical window=10",
+	}, "\n")
+	f, err := config.Parse("init.rc", strings.NewReader(src), parser.AllErrors)
+	if err != nil {
+		t.Fatal(err)
+	}
+	a := FromConfig(f.Services[0])
+	if a.Name != "a" || a.Path != "/bin/a" || strings.Join(a.Args, " ") != "-x y" {
+		t.Fatalf("unexpected %+v service", a)
+	}
+	if !a.HasClass("core") || !a.HasClass("main") || a.HasClass("default") {
+		t.Fatalf("unexpected %q classes", a.Classes)
+	}
+	if a.Restart != RestartNever || !a.Disabled || a.RestartPeriod != 7*time.Second {
+		t.Fatalf("unexpected %+v service", a)
+	}
+	if len(a.Env) != 1 || a.Env[0] != "FOO=bar" {
+		t.Fatalf("unexpected %q env", a.Env)
+	}
+	if a.CrashLoop.Limit != 0 {
+		t.Fatalf("unexpected %+v crash loop", a.CrashLoop)
+	}
+	b := FromConfig(f.Services[1])
+	if !b.HasClass("default") || b.Restart != RestartAlways || b.Disabled || b.RestartPeriod != 0 {
+		t.Fatalf("unexpected %+v service", b)
+	}
+	want := CrashLoop{Limit: 4, Window: 10 * time.Minute, Target: "bootloader"}
+	if b.CrashLoop != want {
+		t.Fatalf("expected %+v crash loop, got %+v", want, b.CrashLoop)
+	}
+	// consecutive restarts back off from the configured period
+	delays := []time.Duration{7 * time.Second, 14 * time.Second, 28 * time.Second, 56 * time.Second, 112 * time.Second, 112 * time.Second}
+	for n, delay := range delays {
+		if got := a.restartDelay(n); got != delay {
+			t.Errorf("expected %s delay after %d restarts, got %s", delay, n, got)
+		}
+	}
+	if got := b.restartDelay(10); got != DefaultMaxRestartFactor*DefaultRestartPeriod {
+		t.Errorf("expected default max restart period, got %s", got)
+	}
+}
+
+func TestStateString(t *testing.T) {
+	if s := Stopping.String(); s != "stopping" {
+		t.Fatalf("expected stopping, got %s", s)
+	}
+	if s := State(42).String(); s != "State(42)" {
+		t.Fatalf("expected State(42), got %s", s)
+	}
+}
+
+// waitFile waits for the file to exist.
+func waitFile(t *testing.T, path string) {
+	t.Helper()
+	deadline := time.Now().Add(testTimeout)
+	for {
+		if _, err := os.Stat(path); err == nil {
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for %s", path)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}